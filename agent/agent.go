@@ -0,0 +1,326 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// 未指定 MaxSteps / Concurrency 时的默认值
+const (
+	defaultMaxSteps    = 8
+	defaultConcurrency = 4
+	defaultMaxRetries  = 2
+)
+
+// 达到步数上限时模型仍在请求工具
+var ErrMaxSteps = errors.New("达到最大步数，模型仍未给出最终回答")
+
+type Config struct {
+	Model model.ToolCallingChatModel
+	Tools []tool.InvokableTool
+	// 一步 = 一次模型调用 + 执行其请求的全部工具，<=0 时使用默认值
+	MaxSteps int
+	// 同一步内多个工具调用的最大并发数，<=0 时使用默认值，1 表示逐个执行
+	Concurrency int
+	// 同一工具的参数连续校验失败时允许模型重试的次数，<=0 时使用默认值
+	MaxRetries int
+	// 每步结束后把轨迹写到这里，nil 表示不输出
+	Trace io.Writer
+}
+
+// Agent 实现 ReAct 循环：反复调用模型、执行其请求的工具并把结果追加到对话，
+// 直到模型不再调用工具或达到步数上限
+type Agent struct {
+	chat        model.BaseChatModel
+	tools       map[string]tool.InvokableTool
+	maxSteps    int
+	concurrency int
+	maxRetries  int
+	trace       io.Writer
+	// 工具名 -> 参数的 JSON Schema，执行前据此校验
+	schemas map[string]*jsonschema.Schema
+}
+
+func New(ctx context.Context, cfg Config) (*Agent, error) {
+	if cfg.Model == nil {
+		return nil, errors.New("agent: 缺少模型")
+	}
+	a := &Agent{
+		tools:       make(map[string]tool.InvokableTool, len(cfg.Tools)),
+		maxSteps:    cfg.MaxSteps,
+		concurrency: cfg.Concurrency,
+		maxRetries:  cfg.MaxRetries,
+		trace:       cfg.Trace,
+		schemas:     make(map[string]*jsonschema.Schema, len(cfg.Tools)),
+	}
+	if a.maxSteps <= 0 {
+		a.maxSteps = defaultMaxSteps
+	}
+	if a.concurrency <= 0 {
+		a.concurrency = defaultConcurrency
+	}
+	if a.maxRetries <= 0 {
+		a.maxRetries = defaultMaxRetries
+	}
+
+	infos := make([]*schema.ToolInfo, 0, len(cfg.Tools))
+	for _, t := range cfg.Tools {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("agent: 读取工具信息失败: %w", err)
+		}
+		if _, dup := a.tools[info.Name]; dup {
+			return nil, fmt.Errorf("agent: 工具 %s 重复注册", info.Name)
+		}
+		params, err := info.ParamsOneOf.ToJSONSchema()
+		if err != nil {
+			return nil, fmt.Errorf("agent: 工具 %s 的参数定义无效: %w", info.Name, err)
+		}
+		a.tools[info.Name] = t
+		a.schemas[info.Name] = params
+		infos = append(infos, info)
+	}
+
+	a.chat = cfg.Model
+	if len(infos) > 0 {
+		bound, err := cfg.Model.WithTools(infos)
+		if err != nil {
+			return nil, fmt.Errorf("agent: 绑定工具失败: %w", err)
+		}
+		a.chat = bound
+	}
+	return a, nil
+}
+
+// 一次工具调用的记录
+type ToolTrace struct {
+	ID        string
+	Name      string
+	Arguments string
+	Result    string
+	Err       error
+	Elapsed   time.Duration
+}
+
+// 一步的记录：模型的输出以及本步执行的工具
+type Step struct {
+	Index     int
+	Content   string
+	Reasoning string
+	Calls     []ToolTrace
+	Usage     *schema.TokenUsage
+	Elapsed   time.Duration
+}
+
+func (s Step) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔁 第 %d 步（%s", s.Index, s.Elapsed.Round(time.Millisecond))
+	if s.Usage != nil {
+		fmt.Fprintf(&b, "，tokens %d", s.Usage.TotalTokens)
+	}
+	b.WriteString("）")
+	if s.Reasoning != "" {
+		fmt.Fprintf(&b, "\n  💭 思考：%s", abbreviate(s.Reasoning, 120))
+	}
+	if s.Content != "" {
+		fmt.Fprintf(&b, "\n  💬 输出：%s", abbreviate(s.Content, 120))
+	}
+	for _, c := range s.Calls {
+		fmt.Fprintf(&b, "\n  🧩 %s(%s)", c.Name, c.Arguments)
+		if c.Err != nil {
+			fmt.Fprintf(&b, " ❌ %v", c.Err)
+		} else {
+			fmt.Fprintf(&b, " -> %s", abbreviate(c.Result, 120))
+		}
+		fmt.Fprintf(&b, "（%s）", c.Elapsed.Round(time.Millisecond))
+	}
+	return b.String()
+}
+
+type Result struct {
+	// 模型最后一次回复；达到步数上限时是仍带工具调用的那条
+	Answer *schema.Message
+	Steps  []Step
+	// 完整对话：输入消息 + 每步的助手消息与工具结果
+	Messages []*schema.Message
+}
+
+// 合计各步的 token 用量
+func (r *Result) Usage() schema.TokenUsage {
+	var total schema.TokenUsage
+	for _, s := range r.Steps {
+		if s.Usage != nil {
+			total.PromptTokens += s.Usage.PromptTokens
+			total.CompletionTokens += s.Usage.CompletionTokens
+			total.TotalTokens += s.Usage.TotalTokens
+		}
+	}
+	return total
+}
+
+// 执行 ReAct 循环。达到步数上限时返回已有的结果与 ErrMaxSteps，便于调用方查看轨迹
+func (a *Agent) Run(ctx context.Context, messages []*schema.Message) (*Result, error) {
+	conv, err := NewConversation(messages...)
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{}
+	// 各工具参数连续校验失败的次数，成功执行一次后清零
+	retries := make(map[string]int)
+	for i := 1; i <= a.maxSteps; i++ {
+		// 每次发送前校验顺序：助手的工具调用消息之后紧跟全部工具结果
+		in, err := conv.Messages()
+		if err != nil {
+			return res, err
+		}
+		res.Messages = in
+
+		start := time.Now()
+		resp, err := a.chat.Generate(ctx, in)
+		if err != nil {
+			return res, fmt.Errorf("第 %d 步调用模型失败: %w", i, err)
+		}
+		res.Answer = resp
+		if err := conv.Append(resp); err != nil {
+			return res, fmt.Errorf("第 %d 步模型回复无效: %w", i, err)
+		}
+
+		step := Step{Index: i, Content: resp.Content, Reasoning: resp.ReasoningContent}
+		if resp.ResponseMeta != nil {
+			step.Usage = resp.ResponseMeta.Usage
+		}
+		// 本步的工具调用并发执行，结果按模型给出的顺序回填，下一步一次性带上全部结果
+		step.Calls = a.invokeAll(ctx, resp.ToolCalls)
+		// 同一步里同一工具的多次失败只算一次重试：它们来自模型的同一次回复。
+		// 本步没有参数错误且至少成功执行一次的工具清零
+		failed, succeeded := make(map[string]bool), make(map[string]bool)
+		for _, tr := range step.Calls {
+			var argErr *ArgumentError
+			switch {
+			case errors.As(tr.Err, &argErr):
+				failed[tr.Name] = true
+			case tr.Err == nil:
+				succeeded[tr.Name] = true
+			}
+		}
+		for name := range failed {
+			retries[name]++
+		}
+		for name := range succeeded {
+			if !failed[name] {
+				delete(retries, name)
+			}
+		}
+		var exhausted error
+		for _, tr := range step.Calls {
+			content := toolContent(tr)
+			// 参数不合法时把结构化的错误交给模型修正，超过重试次数则终止
+			var argErr *ArgumentError
+			if errors.As(tr.Err, &argErr) {
+				// 第 n 次失败后还剩 maxRetries-n+1 次重试机会，为 0 时本步结束后终止
+				content = argErr.feedback(a.maxRetries - retries[tr.Name] + 1)
+				if retries[tr.Name] > a.maxRetries && exhausted == nil {
+					exhausted = fmt.Errorf("%w（已重试 %d 次）: %w", ErrTooManyRetries, a.maxRetries, argErr)
+				}
+			}
+			if err := conv.AddToolOutput(tr.ID, content); err != nil {
+				return res, err
+			}
+		}
+		step.Elapsed = time.Since(start)
+		res.Steps = append(res.Steps, step)
+		res.Messages, _ = conv.Messages()
+		if a.trace != nil {
+			fmt.Fprintln(a.trace, step)
+		}
+
+		if len(resp.ToolCalls) == 0 {
+			return res, nil
+		}
+		if exhausted != nil {
+			return res, exhausted
+		}
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+	}
+	return res, ErrMaxSteps
+}
+
+// 以最多 concurrency 个并发执行一批工具调用，返回的记录与 calls 一一对应
+func (a *Agent) invokeAll(ctx context.Context, calls []schema.ToolCall) []ToolTrace {
+	traces := make([]ToolTrace, len(calls))
+	if len(calls) <= 1 || a.concurrency == 1 {
+		for i, call := range calls {
+			traces[i] = a.invoke(ctx, call)
+		}
+		return traces
+	}
+
+	sem := make(chan struct{}, a.concurrency)
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+				traces[i] = a.invoke(ctx, call)
+			case <-ctx.Done():
+				traces[i] = ToolTrace{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments, Err: ctx.Err()}
+			}
+		}()
+	}
+	wg.Wait()
+	return traces
+}
+
+// 执行一次工具调用。未知工具、工具报错与 panic 都不会中断循环，而是作为工具结果交给模型自行处理
+func (a *Agent) invoke(ctx context.Context, call schema.ToolCall) (tr ToolTrace) {
+	tr = ToolTrace{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments}
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			tr.Result, tr.Err = "", fmt.Errorf("工具 %s panic: %v", call.Function.Name, p)
+		}
+		tr.Elapsed = time.Since(start)
+	}()
+
+	t, ok := a.tools[call.Function.Name]
+	if !ok {
+		tr.Err = fmt.Errorf("未知工具 %s", call.Function.Name)
+		return tr
+	}
+	if tr.Err = validateArgs(call.Function.Name, a.schemas[call.Function.Name], call.Function.Arguments); tr.Err != nil {
+		return tr
+	}
+	tr.Result, tr.Err = t.InvokableRun(ctx, call.Function.Arguments)
+	return tr
+}
+
+func toolContent(tr ToolTrace) string {
+	if tr.Err != nil {
+		return "工具执行失败：" + tr.Err.Error()
+	}
+	return tr.Result
+}
+
+func abbreviate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
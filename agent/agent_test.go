@@ -0,0 +1,249 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"agent-demo/llm"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+type weatherArgs struct {
+	City string `json:"city" required:"true"`
+}
+
+// 模型每一步都并行发出两次参数错误的调用：同一步只算一次重试，
+// remaining_retries 依次为 2、1、0，第 3 步结束后终止
+func TestRetriesCountedOncePerStep(t *testing.T) {
+	chat, err := llm.NewMockChatModel("m", &llm.Script{Rules: []*llm.MockRule{{
+		ToolCalls: []llm.MockToolCall{
+			{Name: "weather", Arguments: map[string]any{"location": "北京"}},
+			{Name: "weather", Arguments: map[string]any{"location": "上海"}},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	weather, err := NewFuncTool("weather", "查询天气", func(ctx context.Context, args weatherArgs) (string, error) {
+		calls++
+		return "晴", nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(context.Background(), Config{Model: chat, Tools: []tool.InvokableTool{weather}, MaxRetries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := a.Run(context.Background(), []*schema.Message{schema.UserMessage("北京和上海天气")})
+	if !errors.Is(err, ErrTooManyRetries) {
+		t.Fatalf("err = %v，期望 ErrTooManyRetries", err)
+	}
+	if calls != 0 {
+		t.Errorf("参数无效时不应执行工具，实际执行 %d 次", calls)
+	}
+	if len(res.Steps) != 3 {
+		t.Fatalf("执行了 %d 步，期望 3 步", len(res.Steps))
+	}
+	var remaining []int
+	for _, msg := range res.Messages {
+		if msg.Role != schema.Tool {
+			continue
+		}
+		var fb struct {
+			RemainingRetries int `json:"remaining_retries"`
+		}
+		if err := json.Unmarshal([]byte(msg.Content), &fb); err != nil {
+			t.Fatalf("工具结果不是结构化错误: %s", msg.Content)
+		}
+		remaining = append(remaining, fb.RemainingRetries)
+	}
+	want := []int{2, 2, 1, 1, 0, 0}
+	if len(remaining) != len(want) {
+		t.Fatalf("remaining_retries = %v，期望 %v", remaining, want)
+	}
+	for i := range want {
+		if remaining[i] != want[i] {
+			t.Fatalf("remaining_retries = %v，期望 %v", remaining, want)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	askWeather := llm.MockRule{Match: "天气", ToolCalls: []llm.MockToolCall{{Name: "weather", Arguments: map[string]any{"city": "北京"}}}}
+	cases := []struct {
+		name     string
+		rules    []*llm.MockRule
+		maxSteps int
+		err      error
+		steps    int
+		answer   string
+		messages int
+	}{
+		{
+			name:     "没有工具调用时直接作为最终回答",
+			rules:    []*llm.MockRule{{Content: "你好"}},
+			steps:    1,
+			answer:   "你好",
+			messages: 2,
+		},
+		{
+			name:     "执行工具后根据结果回答",
+			rules:    []*llm.MockRule{{Role: "tool", Content: "北京今天{{input}}"}, &askWeather},
+			steps:    2,
+			answer:   "北京今天晴",
+			messages: 4,
+		},
+		{
+			name:     "达到步数上限时返回已有的步骤与 ErrMaxSteps",
+			rules:    []*llm.MockRule{{ToolCalls: askWeather.ToolCalls}},
+			maxSteps: 3,
+			err:      ErrMaxSteps,
+			steps:    3,
+			messages: 7,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			chat, err := llm.NewMockChatModel("m", &llm.Script{Rules: tc.rules})
+			if err != nil {
+				t.Fatal(err)
+			}
+			weather, err := NewFuncTool("weather", "查询天气", func(ctx context.Context, args weatherArgs) (string, error) {
+				return "晴", nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := New(context.Background(), Config{Model: chat, Tools: []tool.InvokableTool{weather}, MaxSteps: tc.maxSteps})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := a.Run(context.Background(), []*schema.Message{schema.UserMessage("北京天气怎么样")})
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("err = %v，期望 %v", err, tc.err)
+			}
+			if res == nil || len(res.Steps) != tc.steps {
+				t.Fatalf("执行了 %d 步，期望 %d 步", len(res.Steps), tc.steps)
+			}
+			for i, step := range res.Steps {
+				if step.Index != i+1 {
+					t.Errorf("第 %d 步的 Index = %d", i+1, step.Index)
+				}
+			}
+			if res.Answer.Content != tc.answer {
+				t.Errorf("Answer = %q，期望 %q", res.Answer.Content, tc.answer)
+			}
+			if tc.err == ErrMaxSteps && len(res.Answer.ToolCalls) == 0 {
+				t.Error("达到步数上限时 Answer 应是仍带工具调用的那条回复")
+			}
+			if len(res.Messages) != tc.messages {
+				t.Errorf("对话有 %d 条消息，期望 %d 条", len(res.Messages), tc.messages)
+			}
+
+			var want schema.TokenUsage
+			for _, step := range res.Steps {
+				if step.Usage == nil {
+					t.Fatalf("第 %d 步缺少用量", step.Index)
+				}
+				want.PromptTokens += step.Usage.PromptTokens
+				want.CompletionTokens += step.Usage.CompletionTokens
+				want.TotalTokens += step.Usage.TotalTokens
+			}
+			if got := res.Usage(); got != want || got.TotalTokens == 0 {
+				t.Errorf("Usage() = %+v，期望各步之和 %+v", got, want)
+			}
+		})
+	}
+}
+
+type sleepArgs struct {
+	MS int `json:"ms" required:"true"`
+}
+
+// 同一步的工具调用耗时各不相同：结果仍按调用顺序回填，同时执行的数量不超过 Concurrency
+func TestParallelToolCalls(t *testing.T) {
+	latencies := []int{60, 10, 40, 20, 30}
+	var calls []llm.MockToolCall
+	for _, ms := range latencies {
+		calls = append(calls, llm.MockToolCall{Name: "sleep", Arguments: map[string]any{"ms": ms}})
+	}
+
+	for _, concurrency := range []int{1, 2, 4} {
+		t.Run(fmt.Sprintf("concurrency=%d", concurrency), func(t *testing.T) {
+			chat, err := llm.NewMockChatModel("m", &llm.Script{Rules: []*llm.MockRule{
+				{Role: "tool", Content: "完成"},
+				{ToolCalls: calls},
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var (
+				mu              sync.Mutex
+				inFlight, peak  int
+				started, finish []int
+			)
+			sleep, err := NewFuncTool("sleep", "等待指定毫秒", func(ctx context.Context, args sleepArgs) (string, error) {
+				mu.Lock()
+				inFlight++
+				peak = max(peak, inFlight)
+				started = append(started, args.MS)
+				mu.Unlock()
+				time.Sleep(time.Duration(args.MS) * time.Millisecond)
+				mu.Lock()
+				inFlight--
+				finish = append(finish, args.MS)
+				mu.Unlock()
+				return fmt.Sprintf("slept %d", args.MS), nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := New(context.Background(), Config{Model: chat, Tools: []tool.InvokableTool{sleep}, Concurrency: concurrency})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := a.Run(context.Background(), []*schema.Message{schema.UserMessage("开始")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if peak > concurrency {
+				t.Errorf("同时执行了 %d 个工具，超过 Concurrency=%d", peak, concurrency)
+			}
+			if concurrency > 1 && peak < 2 {
+				t.Errorf("Concurrency=%d 时工具应并发执行，实际最多同时执行 %d 个", concurrency, peak)
+			}
+			if concurrency == 1 && fmt.Sprint(started) != fmt.Sprint(latencies) {
+				t.Errorf("Concurrency=1 时应按调用顺序逐个执行，实际顺序 %v", started)
+			}
+			if concurrency > 1 && fmt.Sprint(finish) == fmt.Sprint(latencies) {
+				t.Errorf("并发执行时完成顺序不应与调用顺序相同，实际 %v", finish)
+			}
+
+			// 第一步的助手消息之后依次是各调用的结果，顺序与调用一致
+			assistant := res.Messages[1]
+			results := res.Messages[2 : 2+len(latencies)]
+			for i, call := range assistant.ToolCalls {
+				if results[i].ToolCallID != call.ID || results[i].Content != fmt.Sprintf("slept %d", latencies[i]) {
+					t.Errorf("第 %d 个结果为 %s / %q，期望对应调用 %s", i, results[i].ToolCallID, results[i].Content, call.ID)
+				}
+			}
+			for i, tr := range res.Steps[0].Calls {
+				if tr.ID != assistant.ToolCalls[i].ID {
+					t.Errorf("Steps[0].Calls[%d] 的 ID = %s，期望 %s", i, tr.ID, assistant.ToolCalls[i].ID)
+				}
+			}
+		})
+	}
+}
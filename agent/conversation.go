@@ -0,0 +1,115 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 消息顺序不符合 OpenAI 兼容接口的要求，发出去会被大多数服务端拒绝
+var ErrInvalidSequence = errors.New("消息顺序无效")
+
+// Conversation 按工具调用的协议组织消息：带 ToolCalls 的助手消息之后，
+// 必须紧跟每个调用 ID 各一条工具结果，全部到齐后才能继续追加其它消息或发送
+type Conversation struct {
+	msgs []*schema.Message
+	// 等待结果的调用 ID -> 工具名，以及调用的原始顺序
+	pending map[string]string
+	order   []string
+}
+
+// 以已有消息开头，例如 system + user；历史中未配对的工具调用同样会被记为待回填
+func NewConversation(messages ...*schema.Message) (*Conversation, error) {
+	c := &Conversation{}
+	for _, msg := range messages {
+		if err := c.Append(msg); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// 追加任意消息并检查顺序：工具结果只能回填给上一条助手消息里的调用，
+// 其它消息只能在所有调用都有结果之后追加
+func (c *Conversation) Append(msg *schema.Message) error {
+	if msg == nil {
+		return fmt.Errorf("%w：第 %d 条消息为空", ErrInvalidSequence, len(c.msgs))
+	}
+	if msg.Role == schema.Tool {
+		return c.AddToolResult(msg)
+	}
+	if len(c.pending) > 0 {
+		return fmt.Errorf("%w：第 %d 条 %s 消息之前，工具调用 %v 还没有结果", ErrInvalidSequence, len(c.msgs), msg.Role, c.Pending())
+	}
+	if msg.Role == schema.Assistant && len(msg.ToolCalls) > 0 {
+		pending := make(map[string]string, len(msg.ToolCalls))
+		order := make([]string, 0, len(msg.ToolCalls))
+		for i, call := range msg.ToolCalls {
+			if call.ID == "" {
+				return fmt.Errorf("%w：第 %d 条消息的第 %d 个工具调用缺少 ID", ErrInvalidSequence, len(c.msgs), i)
+			}
+			if _, dup := pending[call.ID]; dup {
+				return fmt.Errorf("%w：第 %d 条消息的工具调用 ID %s 重复", ErrInvalidSequence, len(c.msgs), call.ID)
+			}
+			pending[call.ID] = call.Function.Name
+			order = append(order, call.ID)
+		}
+		c.pending, c.order = pending, order
+	}
+	c.msgs = append(c.msgs, msg)
+	return nil
+}
+
+// 回填一条工具结果，ToolCallID 必须是尚未回填的调用；未设置 ToolName 时按调用补上
+func (c *Conversation) AddToolResult(msg *schema.Message) error {
+	name, ok := c.pending[msg.ToolCallID]
+	if !ok {
+		return fmt.Errorf("%w：第 %d 条工具结果的调用 ID %q 不属于上一条助手消息，或已回填过", ErrInvalidSequence, len(c.msgs), msg.ToolCallID)
+	}
+	if msg.ToolName == "" {
+		cp := *msg
+		cp.ToolName = name
+		msg = &cp
+	}
+	delete(c.pending, msg.ToolCallID)
+	c.msgs = append(c.msgs, msg)
+	return nil
+}
+
+// 按调用 ID 回填工具结果的便捷写法
+func (c *Conversation) AddToolOutput(callID, content string) error {
+	return c.AddToolResult(schema.ToolMessage(content, callID))
+}
+
+// 尚未回填结果的调用 ID，按模型给出的顺序
+func (c *Conversation) Pending() []string {
+	var ids []string
+	for _, id := range c.order {
+		if _, ok := c.pending[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// 返回可以直接发送的消息列表；仍有工具调用未回填时报错
+func (c *Conversation) Messages() ([]*schema.Message, error) {
+	if len(c.pending) > 0 {
+		return nil, fmt.Errorf("%w：工具调用 %v 还没有结果", ErrInvalidSequence, c.Pending())
+	}
+	if len(c.msgs) == 0 {
+		return nil, fmt.Errorf("%w：消息列表为空", ErrInvalidSequence)
+	}
+	return append([]*schema.Message(nil), c.msgs...), nil
+}
+
+// 检查一段现成的消息列表能否直接发送
+func ValidateMessages(msgs []*schema.Message) error {
+	c, err := NewConversation(msgs...)
+	if err != nil {
+		return err
+	}
+	_, err = c.Messages()
+	return err
+}
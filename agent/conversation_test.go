@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func callMessage(ids ...string) *schema.Message {
+	calls := make([]schema.ToolCall, len(ids))
+	for i, id := range ids {
+		calls[i] = schema.ToolCall{ID: id, Function: schema.FunctionCall{Name: "weather", Arguments: `{"city":"北京"}`}}
+	}
+	return schema.AssistantMessage("", calls)
+}
+
+func TestValidateMessages(t *testing.T) {
+	user := schema.UserMessage("北京和上海天气")
+	cases := []struct {
+		name  string
+		msgs  []*schema.Message
+		valid bool
+	}{
+		{
+			name:  "工具结果齐全后继续对话",
+			msgs:  []*schema.Message{schema.SystemMessage("你是助手"), user, callMessage("c1", "c2"), schema.ToolMessage("雨", "c2"), schema.ToolMessage("晴", "c1"), schema.AssistantMessage("北京晴，上海雨", nil), schema.UserMessage("谢谢")},
+			valid: true,
+		},
+		{
+			name: "工具结果没有对应的调用",
+			msgs: []*schema.Message{user, callMessage("c1"), schema.ToolMessage("晴", "c9")},
+		},
+		{
+			name: "工具结果回填给更早一轮的调用",
+			msgs: []*schema.Message{user, callMessage("c1"), schema.ToolMessage("晴", "c1"), callMessage("c2"), schema.ToolMessage("晴", "c1")},
+		},
+		{
+			name: "同一调用回填两次",
+			msgs: []*schema.Message{user, callMessage("c1", "c2"), schema.ToolMessage("晴", "c1"), schema.ToolMessage("晴", "c1")},
+		},
+		{
+			name: "同一条助手消息里的调用 ID 重复",
+			msgs: []*schema.Message{user, callMessage("c1", "c1"), schema.ToolMessage("晴", "c1")},
+		},
+		{
+			name: "工具调用缺少 ID",
+			msgs: []*schema.Message{user, callMessage("")},
+		},
+		{
+			name: "调用还没有结果就追加助手消息",
+			msgs: []*schema.Message{user, callMessage("c1", "c2"), schema.ToolMessage("晴", "c1"), schema.AssistantMessage("北京晴", nil)},
+		},
+		{
+			name: "调用还没有结果就追加用户消息",
+			msgs: []*schema.Message{user, callMessage("c1"), schema.UserMessage("还有上海")},
+		},
+		{
+			name: "以未回填的调用结尾",
+			msgs: []*schema.Message{user, callMessage("c1")},
+		},
+		{
+			name: "工具结果出现在任何调用之前",
+			msgs: []*schema.Message{schema.ToolMessage("晴", "c1"), user},
+		},
+		{
+			name: "消息为空",
+			msgs: []*schema.Message{user, nil},
+		},
+		{
+			name: "消息列表为空",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMessages(tc.msgs)
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("期望通过校验，实际为 %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidSequence) {
+				t.Fatalf("期望 ErrInvalidSequence，实际为 %v", err)
+			}
+		})
+	}
+}
+
+func TestConversationPendingAndToolName(t *testing.T) {
+	c, err := NewConversation(schema.UserMessage("天气"), callMessage("c1", "c2", "c3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddToolOutput("c2", "晴"); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Pending(); len(got) != 2 || got[0] != "c1" || got[1] != "c3" {
+		t.Errorf("Pending() = %v，期望按调用顺序 [c1 c3]", got)
+	}
+	if _, err := c.Messages(); !errors.Is(err, ErrInvalidSequence) {
+		t.Errorf("仍有调用未回填时 Messages() 应报错，实际为 %v", err)
+	}
+	for _, id := range []string{"c1", "c3"} {
+		if err := c.AddToolOutput(id, "晴"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs, err := c.Messages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tool := msgs[2]; tool.Role != schema.Tool || tool.ToolName != "weather" {
+		t.Errorf("工具结果应按调用补上 ToolName，实际为 %q", tool.ToolName)
+	}
+}
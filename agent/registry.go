@@ -0,0 +1,297 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// Registry 把普通 Go 函数注册为工具：参数结构体通过反射生成 JSON Schema，
+// 调用时自动反序列化参数、序列化返回值。参数结构体支持的标签：
+//
+//	json:"city"          参数名，规则与 encoding/json 一致
+//	desc:"城市名称"      参数说明
+//	required:"true"      必填
+//	enum:"low,mid,high"  取值范围，逗号分隔，按字段类型解析
+//	min:"1" max:"100"    数值的上下限；字符串为长度，数组为项数
+type Registry struct {
+	tools map[string]tool.InvokableTool
+	order []string
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]tool.InvokableTool)}
+}
+
+// 注册任意 InvokableTool，名称重复时报错
+func (r *Registry) Add(ctx context.Context, t tool.InvokableTool) error {
+	info, err := t.Info(ctx)
+	if err != nil {
+		return err
+	}
+	if _, dup := r.tools[info.Name]; dup {
+		return fmt.Errorf("工具 %s 重复注册", info.Name)
+	}
+	r.tools[info.Name] = t
+	r.order = append(r.order, info.Name)
+	return nil
+}
+
+// 按注册顺序返回全部工具，可直接放进 Config.Tools
+func (r *Registry) Tools() []tool.InvokableTool {
+	out := make([]tool.InvokableTool, 0, len(r.order))
+	for _, name := range r.order {
+		out = append(out, r.tools[name])
+	}
+	return out
+}
+
+func (r *Registry) Lookup(name string) (tool.InvokableTool, bool) {
+	t, ok := r.tools[name]
+	return t, ok
+}
+
+// 把 fn 注册为名为 name 的工具。A 必须是结构体（或其指针）；R 为 string 时原样返回，否则序列化为 JSON
+func Register[A, R any](r *Registry, name, desc string, fn func(ctx context.Context, args A) (R, error)) error {
+	t, err := NewFuncTool(name, desc, fn)
+	if err != nil {
+		return err
+	}
+	return r.Add(context.Background(), t)
+}
+
+// FuncTool 是由 Go 函数生成的 InvokableTool
+type FuncTool[A, R any] struct {
+	info *schema.ToolInfo
+	fn   func(ctx context.Context, args A) (R, error)
+}
+
+var _ tool.InvokableTool = (*FuncTool[struct{}, string])(nil)
+
+func NewFuncTool[A, R any](name, desc string, fn func(ctx context.Context, args A) (R, error)) (*FuncTool[A, R], error) {
+	if name == "" {
+		return nil, errors.New("工具名不能为空")
+	}
+	if fn == nil {
+		return nil, fmt.Errorf("工具 %s 缺少实现", name)
+	}
+	typ := reflect.TypeFor[A]()
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("工具 %s 的参数必须是结构体，实际为 %s", name, typ)
+	}
+	params, err := schemaOf(typ, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, fmt.Errorf("工具 %s 的参数: %w", name, err)
+	}
+	return &FuncTool[A, R]{
+		info: &schema.ToolInfo{Name: name, Desc: desc, ParamsOneOf: schema.NewParamsOneOfByJSONSchema(params)},
+		fn:   fn,
+	}, nil
+}
+
+func (t *FuncTool[A, R]) Info(context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *FuncTool[A, R]) InvokableRun(ctx context.Context, arguments string, _ ...tool.Option) (string, error) {
+	args, err := decodeArgs[A](arguments)
+	if err != nil {
+		return "", fmt.Errorf("解析参数失败: %w", err)
+	}
+	out, err := t.fn(ctx, args)
+	if err != nil {
+		return "", err
+	}
+	if s, ok := any(out).(string); ok {
+		return s, nil
+	}
+	data, err := json.Marshal(out)
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// 模型偶尔给出空字符串当作无参数；未声明的字段视为错误，让模型知道参数名写错了
+func decodeArgs[A any](arguments string) (A, error) {
+	var args A
+	ptr := reflect.New(reflect.TypeFor[A]())
+	if reflect.TypeFor[A]().Kind() == reflect.Pointer {
+		ptr.Elem().Set(reflect.New(reflect.TypeFor[A]().Elem()))
+	}
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(arguments)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(ptr.Interface()); err != nil {
+		return args, err
+	}
+	return ptr.Elem().Interface().(A), nil
+}
+
+var timeType = reflect.TypeFor[time.Time]()
+
+// 生成 Go 类型对应的 JSON Schema；seen 用于发现递归类型
+func schemaOf(typ reflect.Type, seen map[reflect.Type]bool) (*jsonschema.Schema, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == timeType {
+		return &jsonschema.Schema{Type: string(schema.String), Format: "date-time"}, nil
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return &jsonschema.Schema{Type: string(schema.String)}, nil
+	case reflect.Bool:
+		return &jsonschema.Schema{Type: string(schema.Boolean)}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonschema.Schema{Type: string(schema.Integer)}, nil
+	case reflect.Float32, reflect.Float64:
+		return &jsonschema.Schema{Type: string(schema.Number)}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := schemaOf(typ.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonschema.Schema{Type: string(schema.Array), Items: items}, nil
+	case reflect.Map:
+		if typ.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("map 的键必须是 string，实际为 %s", typ.Key())
+		}
+		values, err := schemaOf(typ.Elem(), seen)
+		if err != nil {
+			return nil, err
+		}
+		return &jsonschema.Schema{Type: string(schema.Object), AdditionalProperties: values}, nil
+	case reflect.Struct:
+		return structSchema(typ, seen)
+	default:
+		return nil, fmt.Errorf("不支持的类型 %s", typ)
+	}
+}
+
+func structSchema(typ reflect.Type, seen map[reflect.Type]bool) (*jsonschema.Schema, error) {
+	if seen[typ] {
+		return nil, fmt.Errorf("不支持递归类型 %s", typ)
+	}
+	seen[typ] = true
+	defer delete(seen, typ)
+
+	s := &jsonschema.Schema{Type: string(schema.Object), Properties: jsonschema.NewProperties()}
+	for _, field := range reflect.VisibleFields(typ) {
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		prop, err := schemaOf(field.Type, seen)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		prop.Description = field.Tag.Get("desc")
+		if enum := field.Tag.Get("enum"); enum != "" {
+			if prop.Enum, err = parseEnum(field.Type, enum); err != nil {
+				return nil, fmt.Errorf("%s 的 enum: %w", name, err)
+			}
+		}
+		if err := applyLimits(prop, field.Tag); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if required, _ := strconv.ParseBool(field.Tag.Get("required")); required {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties.Set(name, prop)
+	}
+	return s, nil
+}
+
+// 解析 min / max 标签，按 schema 类型落到数值范围、字符串长度或数组项数上
+func applyLimits(s *jsonschema.Schema, tag reflect.StructTag) error {
+	for _, key := range []string{"min", "max"} {
+		raw := tag.Get(key)
+		if raw == "" {
+			continue
+		}
+		switch schema.DataType(s.Type) {
+		case schema.Integer, schema.Number:
+			if _, err := strconv.ParseFloat(raw, 64); err != nil {
+				return fmt.Errorf("%s 标签不是数字: %q", key, raw)
+			}
+			if key == "min" {
+				s.Minimum = json.Number(raw)
+			} else {
+				s.Maximum = json.Number(raw)
+			}
+		case schema.String, schema.Array:
+			n, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%s 标签不是非负整数: %q", key, raw)
+			}
+			switch {
+			case s.Type == string(schema.String) && key == "min":
+				s.MinLength = &n
+			case s.Type == string(schema.String):
+				s.MaxLength = &n
+			case key == "min":
+				s.MinItems = &n
+			default:
+				s.MaxItems = &n
+			}
+		default:
+			return fmt.Errorf("%s 类型不支持 %s 标签", s.Type, key)
+		}
+	}
+	return nil
+}
+
+// 按字段类型解析 enum 标签，保证 schema 里的取值与参数反序列化后的类型一致
+func parseEnum(typ reflect.Type, tag string) ([]any, error) {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	var values []any
+	for _, raw := range strings.Split(tag, ",") {
+		raw = strings.TrimSpace(raw)
+		switch typ.Kind() {
+		case reflect.String:
+			values = append(values, raw)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		case reflect.Float32, reflect.Float64:
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		default:
+			return nil, fmt.Errorf("%s 类型不支持 enum", typ)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,169 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type searchArgs struct {
+	Query    string     `json:"query" desc:"关键词" required:"true" min:"1" max:"50"`
+	Limit    int        `json:"limit,omitempty" desc:"条数" min:"1" max:"20"`
+	Level    *string    `json:"level,omitempty" enum:"low,high"`
+	Stars    int        `json:"stars,omitempty" enum:"3,4,5"`
+	Tags     []string   `json:"tags,omitempty" max:"3"`
+	Since    *time.Time `json:"since,omitempty"`
+	Internal string     `json:"-"`
+	hidden   int
+}
+
+func TestRegisterGeneratesSchema(t *testing.T) {
+	reg := NewRegistry()
+	if err := Register(reg, "search", "搜索", func(ctx context.Context, args searchArgs) (string, error) {
+		return args.Query, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	tl, ok := reg.Lookup("search")
+	if !ok {
+		t.Fatal("找不到已注册的工具")
+	}
+	info, err := tl.Info(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := info.ParamsOneOf.ToJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有 required 的字段必填，指针字段与 omitempty 字段都是可选的；json:"-" 与未导出字段不出现
+	want := `{
+		"type": "object",
+		"required": ["query"],
+		"properties": {
+			"query": {"type": "string", "description": "关键词", "minLength": 1, "maxLength": 50},
+			"limit": {"type": "integer", "description": "条数", "minimum": 1, "maximum": 20},
+			"level": {"type": "string", "enum": ["low", "high"]},
+			"stars": {"type": "integer", "enum": [3, 4, 5]},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 3},
+			"since": {"type": "string", "format": "date-time"}
+		}
+	}`
+	var got, expected any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("生成的 schema = %s", raw)
+	}
+	if info.Name != "search" || info.Desc != "搜索" {
+		t.Errorf("工具信息 = %s / %s", info.Name, info.Desc)
+	}
+}
+
+func TestRegisterRejectsInvalidDefinitions(t *testing.T) {
+	type recursive struct {
+		Children []recursive `json:"children"`
+	}
+	type badLimit struct {
+		Flag bool `json:"flag" min:"1"`
+	}
+	type badEnum struct {
+		Count int `json:"count" enum:"one,two"`
+	}
+	type badMap struct {
+		Scores map[int]string `json:"scores"`
+	}
+
+	reg := NewRegistry()
+	noop := func(ctx context.Context, args searchArgs) (string, error) { return "", nil }
+	if err := Register(reg, "search", "搜索", noop); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]error{
+		"名称重复":        Register(reg, "search", "搜索", noop),
+		"名称为空":        Register(reg, "", "搜索", noop),
+		"参数不是结构体":     Register(reg, "echo", "", func(ctx context.Context, args string) (string, error) { return args, nil }),
+		"递归类型":        Register(reg, "tree", "", func(ctx context.Context, args recursive) (string, error) { return "", nil }),
+		"类型不支持的标签":    Register(reg, "flag", "", func(ctx context.Context, args badLimit) (string, error) { return "", nil }),
+		"枚举值与类型不符":    Register(reg, "count", "", func(ctx context.Context, args badEnum) (string, error) { return "", nil }),
+		"map 的键不是字符串": Register(reg, "scores", "", func(ctx context.Context, args badMap) (string, error) { return "", nil }),
+	}
+	for name, err := range cases {
+		if err == nil {
+			t.Errorf("%s：期望注册失败", name)
+		}
+	}
+	if len(reg.Tools()) != 1 {
+		t.Errorf("注册失败的工具不应加入 Registry，实际有 %d 个", len(reg.Tools()))
+	}
+}
+
+func TestDecodeArgs(t *testing.T) {
+	args, err := decodeArgs[searchArgs](`{"query":"咖啡","limit":5,"level":"high","tags":["a"],"since":"2025-03-05T10:00:00+08:00"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Query != "咖啡" || args.Limit != 5 || args.Level == nil || *args.Level != "high" || len(args.Tags) != 1 || args.Since == nil {
+		t.Errorf("解析结果 = %+v", args)
+	}
+
+	// 可选的指针字段不传时为 nil，空字符串按无参数处理
+	for _, in := range []string{`{"query":"咖啡"}`, "  "} {
+		args, err := decodeArgs[searchArgs](in)
+		if err != nil {
+			t.Fatalf("%q: %v", in, err)
+		}
+		if args.Level != nil || args.Since != nil {
+			t.Errorf("%q: 未传的指针字段应为 nil，实际为 %+v", in, args)
+		}
+	}
+
+	// 参数类型为指针时同样可用
+	ptr, err := decodeArgs[*searchArgs](`{"query":"茶"}`)
+	if err != nil || ptr == nil || ptr.Query != "茶" {
+		t.Errorf("指针参数解析结果 = %+v，错误 %v", ptr, err)
+	}
+
+	bad := map[string]string{
+		"未声明的参数":    `{"query":"咖啡","size":5}`,
+		"类型不符":      `{"query":"咖啡","limit":"5"}`,
+		"不是合法 JSON": `{"query":`,
+		"不是对象":      `["咖啡"]`,
+	}
+	for name, in := range bad {
+		if _, err := decodeArgs[searchArgs](in); err == nil {
+			t.Errorf("%s：%s 应返回错误", name, in)
+		}
+	}
+}
+
+func TestFuncToolInvokableRun(t *testing.T) {
+	type result struct {
+		Count int `json:"count"`
+	}
+	tl, err := NewFuncTool("count", "统计", func(ctx context.Context, args searchArgs) (result, error) {
+		return result{Count: args.Limit}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tl.InvokableRun(context.Background(), `{"query":"x","limit":3}`)
+	if err != nil || out != `{"count":3}` {
+		t.Errorf("结果 = %s，错误 %v", out, err)
+	}
+	if _, err := tl.InvokableRun(context.Background(), `{"query":"x","limt":3}`); err == nil || !strings.Contains(err.Error(), "解析参数失败") {
+		t.Errorf("参数名写错时应返回解析错误，实际为 %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// 同一工具的参数连续校验失败超过 MaxRetries 次
+var ErrTooManyRetries = errors.New("工具参数多次校验失败")
+
+// 参数中的一处问题，Field 为空表示整体（例如不是合法的 JSON）
+type FieldProblem struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ArgumentError 表示模型给出的参数不符合工具的 JSON Schema，工具不会被执行
+type ArgumentError struct {
+	Tool     string
+	Problems []FieldProblem
+}
+
+func (e *ArgumentError) Error() string {
+	parts := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		if p.Field == "" {
+			parts = append(parts, p.Message)
+		} else {
+			parts = append(parts, p.Field+" "+p.Message)
+		}
+	}
+	return fmt.Sprintf("工具 %s 的参数无效：%s", e.Tool, strings.Join(parts, "；"))
+}
+
+// 回填给模型的结构化错误，附带剩余重试次数，引导模型按 schema 修正后重新调用
+func (e *ArgumentError) feedback(remaining int) string {
+	data, _ := json.Marshal(struct {
+		Error            string         `json:"error"`
+		Tool             string         `json:"tool"`
+		Problems         []FieldProblem `json:"problems"`
+		RemainingRetries int            `json:"remaining_retries"`
+		Hint             string         `json:"hint"`
+	}{
+		Error:            "invalid_arguments",
+		Tool:             e.Tool,
+		Problems:         e.Problems,
+		RemainingRetries: max(remaining, 0),
+		Hint:             "工具没有执行。请按参数说明修正上述问题后重新调用该工具。",
+	})
+	return string(data)
+}
+
+// 按 JSON Schema 校验参数，s 为 nil 时不校验
+func validateArgs(toolName string, s *jsonschema.Schema, arguments string) error {
+	if s == nil {
+		return nil
+	}
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	dec := json.NewDecoder(bytes.NewReader([]byte(arguments)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		msg := "参数不是合法的 JSON 对象"
+		if err != nil {
+			msg += "：" + err.Error()
+		}
+		return &ArgumentError{Tool: toolName, Problems: []FieldProblem{{Message: msg}}}
+	}
+	var problems []FieldProblem
+	check(s, v, "", &problems)
+	if len(problems) > 0 {
+		return &ArgumentError{Tool: toolName, Problems: problems}
+	}
+	return nil
+}
+
+func check(s *jsonschema.Schema, v any, path string, problems *[]FieldProblem) {
+	report := func(format string, args ...any) {
+		*problems = append(*problems, FieldProblem{Field: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.Type != "" && !matchesType(schema.DataType(s.Type), v) {
+		report("应为 %s，实际为 %s", s.Type, typeName(v))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		report("取值必须是 %s 之一", formatEnum(s.Enum))
+	}
+
+	switch v := v.(type) {
+	case string:
+		n := uint64(utf8.RuneCountInString(v))
+		if s.MinLength != nil && n < *s.MinLength {
+			report("长度不能少于 %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			report("长度不能超过 %d", *s.MaxLength)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if limit, ok := number(s.Minimum); ok && f < limit {
+			report("不能小于 %s", s.Minimum)
+		}
+		if limit, ok := number(s.Maximum); ok && f > limit {
+			report("不能大于 %s", s.Maximum)
+		}
+		if limit, ok := number(s.ExclusiveMinimum); ok && f <= limit {
+			report("必须大于 %s", s.ExclusiveMinimum)
+		}
+		if limit, ok := number(s.ExclusiveMaximum); ok && f >= limit {
+			report("必须小于 %s", s.ExclusiveMaximum)
+		}
+	case []any:
+		n := uint64(len(v))
+		if s.MinItems != nil && n < *s.MinItems {
+			report("至少需要 %d 项", *s.MinItems)
+		}
+		if s.MaxItems != nil && n > *s.MaxItems {
+			report("最多 %d 项", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				check(s.Items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if val, ok := v[name]; !ok || val == nil {
+				*problems = append(*problems, FieldProblem{Field: join(path, name), Message: "是必填参数"})
+			}
+		}
+		// 声明了属性的对象，多出来的键通常是参数名写错了，同样提示给模型
+		for _, name := range slices.Sorted(maps.Keys(v)) {
+			val := v[name]
+			var prop *jsonschema.Schema
+			if s.Properties != nil {
+				prop, _ = s.Properties.Get(name)
+			}
+			switch {
+			case prop != nil:
+				if val != nil {
+					check(prop, val, join(path, name), problems)
+				}
+			case s.AdditionalProperties != nil:
+				check(s.AdditionalProperties, val, join(path, name), problems)
+			case s.Properties != nil:
+				*problems = append(*problems, FieldProblem{Field: join(path, name), Message: "不是该工具的参数"})
+			}
+		}
+	}
+}
+
+func matchesType(typ schema.DataType, v any) bool {
+	switch typ {
+	case schema.String:
+		_, ok := v.(string)
+		return ok
+	case schema.Boolean:
+		_, ok := v.(bool)
+		return ok
+	case schema.Number:
+		_, ok := v.(json.Number)
+		return ok
+	case schema.Integer:
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case schema.Array:
+		_, ok := v.([]any)
+		return ok
+	case schema.Object:
+		_, ok := v.(map[string]any)
+		return ok
+	case schema.Null:
+		return v == nil
+	default:
+		return true
+	}
+}
+
+func typeName(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return string(schema.String)
+	case bool:
+		return string(schema.Boolean)
+	case json.Number:
+		if _, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return string(schema.Integer)
+		}
+		return string(schema.Number)
+	case []any:
+		return string(schema.Array)
+	default:
+		return string(schema.Object)
+	}
+}
+
+// 数字按数值比较，schema 中的 1 与参数中的 1.0 视为相同
+func inEnum(enum []any, v any) bool {
+	for _, e := range enum {
+		if n, ok := v.(json.Number); ok {
+			f, _ := n.Float64()
+			if ef, ok := toFloat(e); ok && ef == f {
+				return true
+			}
+			continue
+		}
+		switch v.(type) {
+		case string, bool:
+			if e == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func formatEnum(enum []any) string {
+	parts := make([]string, len(enum))
+	for i, e := range enum {
+		data, _ := json.Marshal(e)
+		parts[i] = string(data)
+	}
+	return strings.Join(parts, " / ")
+}
+
+func number(n json.Number) (float64, bool) {
+	if n == "" {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
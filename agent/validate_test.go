@@ -0,0 +1,134 @@
+package agent
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type bookingArgs struct {
+	City   string         `json:"city" required:"true" min:"1" max:"5"`
+	Nights int            `json:"nights" required:"true" min:"1" max:"30"`
+	Level  string         `json:"level,omitempty" enum:"standard,deluxe"`
+	Stars  int            `json:"stars,omitempty" enum:"3,4,5"`
+	Guests []string       `json:"guests,omitempty" min:"1" max:"2"`
+	Extra  map[string]int `json:"extra,omitempty"`
+	Notes  *bookingNotes  `json:"notes,omitempty"`
+}
+
+type bookingNotes struct {
+	Late bool `json:"late"`
+}
+
+func TestValidateArgs(t *testing.T) {
+	s, err := schemaOf(reflect.TypeFor[bookingArgs](), map[reflect.Type]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		args     string
+		problems []FieldProblem
+	}{
+		{name: "合法参数", args: `{"city":"北京","nights":2,"level":"deluxe","stars":4.0,"guests":["张三"],"notes":{"late":true}}`},
+		{name: "可选字段为 null 时跳过", args: `{"city":"北京","nights":1,"notes":null}`},
+		{
+			name:     "空参数按空对象处理",
+			args:     " ",
+			problems: []FieldProblem{{Field: "city", Message: "是必填参数"}, {Field: "nights", Message: "是必填参数"}},
+		},
+		{
+			name:     "必填参数为 null",
+			args:     `{"city":null,"nights":1}`,
+			problems: []FieldProblem{{Field: "city", Message: "是必填参数"}},
+		},
+		{
+			name:     "类型不符",
+			args:     `{"city":"北京","nights":"两晚"}`,
+			problems: []FieldProblem{{Field: "nights", Message: "应为 integer，实际为 string"}},
+		},
+		{
+			name:     "整数不能带小数",
+			args:     `{"city":"北京","nights":1.5}`,
+			problems: []FieldProblem{{Field: "nights", Message: "应为 integer，实际为 number"}},
+		},
+		{
+			name: "数值范围与字符串长度按字符计",
+			args: `{"city":"乌鲁木齐市","nights":31}`,
+			problems: []FieldProblem{
+				{Field: "nights", Message: "不能大于 30"},
+			},
+		},
+		{
+			name:     "字符串过长",
+			args:     `{"city":"乌鲁木齐市区内","nights":0}`,
+			problems: []FieldProblem{{Field: "city", Message: "长度不能超过 5"}, {Field: "nights", Message: "不能小于 1"}},
+		},
+		{
+			name: "枚举取值",
+			args: `{"city":"北京","nights":1,"level":"suite","stars":2}`,
+			problems: []FieldProblem{
+				{Field: "level", Message: `取值必须是 "standard" / "deluxe" 之一`},
+				{Field: "stars", Message: "取值必须是 3 / 4 / 5 之一"},
+			},
+		},
+		{
+			name: "数组项数与元素类型",
+			args: `{"city":"北京","nights":1,"guests":["a","b",3]}`,
+			problems: []FieldProblem{
+				{Field: "guests", Message: "最多 2 项"},
+				{Field: "guests[2]", Message: "应为 string，实际为 integer"},
+			},
+		},
+		{
+			name:     "map 的值按 additionalProperties 校验",
+			args:     `{"city":"北京","nights":1,"extra":{"breakfast":"yes"}}`,
+			problems: []FieldProblem{{Field: "extra.breakfast", Message: "应为 integer，实际为 string"}},
+		},
+		{
+			name:     "嵌套对象与多余的参数",
+			args:     `{"city":"北京","nights":1,"notes":{"late":"yes"},"night":2}`,
+			problems: []FieldProblem{{Field: "night", Message: "不是该工具的参数"}, {Field: "notes.late", Message: "应为 boolean，实际为 string"}},
+		},
+		{
+			name:     "不是对象",
+			args:     `["北京"]`,
+			problems: []FieldProblem{{Message: "应为 object，实际为 array"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateArgs("book_hotel", s, tc.args)
+			if len(tc.problems) == 0 {
+				if err != nil {
+					t.Fatalf("期望通过校验，实际为 %v", err)
+				}
+				return
+			}
+			var argErr *ArgumentError
+			if !errors.As(err, &argErr) {
+				t.Fatalf("期望 *ArgumentError，实际为 %v", err)
+			}
+			if argErr.Tool != "book_hotel" || !reflect.DeepEqual(argErr.Problems, tc.problems) {
+				t.Errorf("问题列表 = %+v\n期望 %+v", argErr.Problems, tc.problems)
+			}
+		})
+	}
+}
+
+func TestValidateArgsInvalidJSON(t *testing.T) {
+	s, err := schemaOf(reflect.TypeFor[bookingArgs](), map[reflect.Type]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range []string{`{"city":`, `{"city":"北京"} {"nights":1}`} {
+		var argErr *ArgumentError
+		if err := validateArgs("book_hotel", s, args); !errors.As(err, &argErr) || argErr.Problems[0].Field != "" {
+			t.Errorf("%s 应报告为不合法的 JSON，实际为 %v", args, err)
+		}
+	}
+	if err := validateArgs("book_hotel", nil, "不是 JSON"); err != nil {
+		t.Errorf("schema 为 nil 时不应校验，实际为 %v", err)
+	}
+}
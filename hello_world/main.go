@@ -4,30 +4,26 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
+	"agent-demo/llm"
+
 	"github.com/cloudwego/eino/schema"
 )
 
 func main() {
 	ctx := context.Background()
 
-	apiKey := os.Getenv("ARK_API_KEY")
-	baseURL := os.Getenv("ARK_BASE_URL")
-	modelID := os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请先设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL 环境变量")
+	// ARK_BASE_URL 例如 https://ark.cn-beijing.volces.com/api/v3，ARK_MODEL 为 deepseek-v3-1-terminus 或 ep-xxxx；
+	// 不设置 ARK_* 时使用离线 mock 模型
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// 初始化 Ark ChatModel（走 Ark 的 OpenAI 兼容服务）
-	chatModel, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL, // 例如: https://ark.cn-beijing.volces.com/api/v3
-		APIKey:  apiKey,  // 等同于 curl 的 Authorization: Bearer <key>
-		Model:   modelID, // deepseek-v3-1-terminus 或 ep-xxxx
-	})
+	// 按 LLM_PROVIDER 初始化 ChatModel（ark / openai 兼容服务 / mock）
+	chatModel, err := llm.NewChatModel(ctx, cfg)
 	if err != nil {
-		log.Fatalf("初始化 Ark ChatModel 失败: %v", err)
+		log.Fatalf("初始化 ChatModel 失败: %v", err)
 	}
 
 	// 与你的 curl 一致的消息
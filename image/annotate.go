@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// 模型返回的问题区域（坐标为原图像素，左上角为原点）
+type region struct {
+	Label string `json:"label"`
+	Issue string `json:"issue"`
+	X1    int    `json:"x1"`
+	Y1    int    `json:"y1"`
+	X2    int    `json:"x2"`
+	Y2    int    `json:"y2"`
+}
+
+type regionReport struct {
+	Summary string   `json:"summary"`
+	Regions []region `json:"regions"`
+}
+
+// 约束模型输出的 JSON Schema
+var regionReportSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"summary": map[string]any{"type": "string", "description": "整体评价"},
+		"regions": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"label": map[string]any{"type": "string", "description": "英文短标签（ASCII，不超过 24 个字符），用于图例"},
+					"issue": map[string]any{"type": "string", "description": "问题描述与改进建议"},
+					"x1":    map[string]any{"type": "integer", "description": "左上角 x（像素）"},
+					"y1":    map[string]any{"type": "integer", "description": "左上角 y（像素）"},
+					"x2":    map[string]any{"type": "integer", "description": "右下角 x（像素）"},
+					"y2":    map[string]any{"type": "integer", "description": "右下角 y（像素）"},
+				},
+				"required":             []string{"label", "issue", "x1", "y1", "x2", "y2"},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"summary", "regions"},
+	"additionalProperties": false,
+}
+
+var boxPalette = []color.RGBA{
+	{R: 230, G: 57, B: 70, A: 255},
+	{R: 29, G: 114, B: 243, A: 255},
+	{R: 46, G: 160, B: 67, A: 255},
+	{R: 245, G: 159, B: 0, A: 255},
+	{R: 142, G: 68, B: 173, A: 255},
+	{R: 0, G: 150, B: 150, A: 255},
+}
+
+const (
+	// 图例标签的最大长度，与 Schema 中对 label 的要求一致
+	maxLabelLen  = 24
+	boxStroke    = 3
+	legendRowH   = 20
+	legendPad    = 8
+	legendSwatch = 12
+)
+
+// 校验并裁剪区域：整体越界或面积为 0 的区域直接丢弃，部分越界的裁剪到图片范围内
+func validateRegions(regions []region, width, height int) ([]region, []error) {
+	bounds := image.Rect(0, 0, width, height)
+	valid := make([]region, 0, len(regions))
+	var errs []error
+	for i, r := range regions {
+		if r.X1 > r.X2 {
+			r.X1, r.X2 = r.X2, r.X1
+		}
+		if r.Y1 > r.Y2 {
+			r.Y1, r.Y2 = r.Y2, r.Y1
+		}
+		rect := image.Rect(r.X1, r.Y1, r.X2, r.Y2)
+		clipped := rect.Intersect(bounds)
+		if clipped.Empty() {
+			errs = append(errs, fmt.Errorf("区域 %d（%s）坐标 %v 超出图片范围 %dx%d", i+1, r.Label, rect, width, height))
+			continue
+		}
+		if clipped != rect {
+			errs = append(errs, fmt.Errorf("区域 %d（%s）坐标 %v 部分越界，已裁剪为 %v", i+1, r.Label, rect, clipped))
+		}
+		r.X1, r.Y1, r.X2, r.Y2 = clipped.Min.X, clipped.Min.Y, clipped.Max.X, clipped.Max.Y
+		r.Label = asciiLabel(r.Label)
+		if len(r.Label) > maxLabelLen {
+			short := r.Label[:maxLabelLen-3] + "..."
+			errs = append(errs, fmt.Errorf("区域 %d 的标签 %q 超过 %d 个字符，已截断为 %q", i+1, r.Label, maxLabelLen, short))
+			r.Label = short
+		}
+		valid = append(valid, r)
+	}
+	return valid, errs
+}
+
+// 在原图上绘制编号框，并在图片下方追加图例
+func renderAnnotated(src image.Image, regions []region) *image.RGBA {
+	b := src.Bounds()
+	legendH := 0
+	if len(regions) > 0 {
+		legendH = legendPad*2 + legendRowH*len(regions)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()+legendH))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(0, 0, b.Dx(), b.Dy()), src, b.Min, draw.Src)
+
+	for i, r := range regions {
+		c := boxPalette[i%len(boxPalette)]
+		strokeRect(dst, image.Rect(r.X1, r.Y1, r.X2, r.Y2), c)
+
+		// 编号标签贴在框的左上角，空间不够时放到框内
+		tag := fmt.Sprintf("%d", i+1)
+		tagW := textWidth(tag) + 6
+		tagRect := image.Rect(r.X1, r.Y1-legendSwatch-4, r.X1+tagW, r.Y1)
+		if tagRect.Min.Y < 0 {
+			tagRect = tagRect.Add(image.Pt(0, legendSwatch+4))
+		}
+		draw.Draw(dst, tagRect, image.NewUniform(c), image.Point{}, draw.Src)
+		drawText(dst, tag, tagRect.Min.X+3, tagRect.Max.Y-3, color.White)
+	}
+
+	for i, r := range regions {
+		c := boxPalette[i%len(boxPalette)]
+		top := b.Dy() + legendPad + i*legendRowH
+		swatch := image.Rect(legendPad, top+4, legendPad+legendSwatch, top+4+legendSwatch)
+		draw.Draw(dst, swatch, image.NewUniform(c), image.Point{}, draw.Src)
+		x := swatch.Max.X + 6
+		drawText(dst, fitText(fmt.Sprintf("%d. %s", i+1, r.Label), b.Dx()-x-legendPad), x, swatch.Max.Y, color.Black)
+	}
+	return dst
+}
+
+// 输出到输入图片同目录：xxx.png -> xxx.annotated.png
+func saveAnnotated(inputPath string, img image.Image) (string, error) {
+	ext := filepath.Ext(inputPath)
+	outPath := strings.TrimSuffix(inputPath, ext) + ".annotated.png"
+	f, err := os.Create(outPath)
+	if err != nil {
+		return "", err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return "", err
+	}
+	// 写入失败可能到 Close 时才暴露，不检查会把不完整的图片当作成功
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return outPath, nil
+}
+
+func strokeRect(dst draw.Image, r image.Rectangle, c color.Color) {
+	u := image.NewUniform(c)
+	t := boxStroke
+	draw.Draw(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+t), u, image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Max.Y-t, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(r.Min.X, r.Min.Y, r.Min.X+t, r.Max.Y), u, image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(r.Max.X-t, r.Min.Y, r.Max.X, r.Max.Y), u, image.Point{}, draw.Src)
+}
+
+func drawText(dst draw.Image, text string, x, y int, c color.Color) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func textWidth(text string) int {
+	return font.MeasureString(basicfont.Face7x13, text).Ceil()
+}
+
+// 图片较窄时图例放不下整行，截断到 maxWidth 像素以内并以 "..." 结尾
+func fitText(text string, maxWidth int) string {
+	if textWidth(text) <= maxWidth {
+		return text
+	}
+	for n := len(text) - 1; n > 0; n-- {
+		if short := text[:n] + "..."; textWidth(short) <= maxWidth {
+			return short
+		}
+	}
+	return ""
+}
+
+// basicfont 只包含 ASCII 字形，其余字符替换为 '?'
+func asciiLabel(s string) string {
+	s = strings.TrimSpace(s)
+	var builder strings.Builder
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			r = '?'
+		}
+		builder.WriteRune(r)
+	}
+	if builder.Len() == 0 {
+		return "region"
+	}
+	return builder.String()
+}
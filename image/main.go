@@ -1,53 +1,91 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
 	"log"
 	"os"
 
+	"agent-demo/llm"
+	"agent-demo/visioncache"
+
 	"github.com/cloudwego/eino-ext/components/model/ark"
 	"github.com/cloudwego/eino/schema"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
 )
 
 func main() {
 	ctx := context.Background()
 
-	apiKey := os.Getenv("ARK_API_KEY")
-	baseURL := os.Getenv("ARK_BASE_URL")
-	modelID := os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请先设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL 环境变量")
+	imagePath := flag.String("image", "/Users/bytedance/Documents/codework/openSource/eino/image/image1.png", "待分析的本地图片路径")
+	noCache := flag.Bool("no-cache", false, "跳过视觉分析缓存，强制调用模型")
+	cacheDir := flag.String("cache-dir", "", "缓存目录，默认为用户缓存目录下的 agent-demo/vision")
+	cacheTTL := flag.Duration("cache-ttl", visioncache.DefaultTTL, "缓存有效期，<=0 表示永不过期")
+	flag.Parse()
+
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Provider == llm.ProviderMock && cfg.MockScript == "" {
+		cfg.MockScript = "image/mock.yaml"
+	}
+	// 要求模型按 Schema 输出问题区域，便于回绘到图片上
+	cfg.ResponseFormat = &ark.ResponseFormat{
+		Type: arkmodel.ResponseFormatJSONSchema,
+		JSONSchema: &arkmodel.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:        "region_report",
+			Description: "图片中存在问题的区域及改进建议",
+			Schema:      regionReportSchema,
+			Strict:      true,
+		},
+	}
+	chat, err := llm.NewChatModel(ctx, cfg)
+	if err != nil {
+		log.Fatalf("初始化 ChatModel 失败: %v", err)
 	}
 
-	chat, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL,
-		APIKey:  apiKey,
-		Model:   modelID,
-	})
+	cache, err := visioncache.New(*cacheDir, *cacheTTL)
 	if err != nil {
-		log.Fatalf("初始化 Ark ChatModel 失败: %v", err)
+		log.Fatalf("初始化视觉缓存失败: %v", err)
+	}
+	if *noCache {
+		cache.Disable()
 	}
 
 	// ========= 使用本地图片 =========
-	imagePath := "/Users/bytedance/Documents/codework/openSource/eino/image/image1.png"
-	imageBytes, err := os.ReadFile(imagePath)
+	imageBytes, err := os.ReadFile(*imagePath)
 	if err != nil {
 		log.Fatalf("读取本地图片失败: %v", err)
 	}
+	src, format, err := image.Decode(bytes.NewReader(imageBytes))
+	if err != nil {
+		log.Fatalf("解码图片失败: %v", err)
+	}
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+	mimeType := "image/" + format
 
 	userMsg := schema.UserMessage("")
 	userMsg.MultiContent = []schema.ChatMessagePart{
 		{
 			Type: schema.ChatMessagePartTypeText,
-			Text: "请评估该仪表盘的对齐、留白、层级与配色、字体颜色对比度对可阅读性的影响，并给出3条可执行改进建议。",
+			Text: fmt.Sprintf("请评估该仪表盘的对齐、留白、层级与配色、字体颜色对比度对可阅读性的影响，并给出3条可执行改进建议。"+
+				"对每个存在问题的区域给出边界框，坐标以原图像素为单位，原点在左上角，图片尺寸为 %d x %d。", width, height),
 		},
 		{
 			Type: schema.ChatMessagePartTypeImageURL,
 			ImageURL: &schema.ChatMessageImageURL{
-				URL:      fmt.Sprintf("data:image/png;base64,%s", base64.StdEncoding.EncodeToString(imageBytes)),
-				MIMEType: "image/png",
+				URL:      fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(imageBytes)),
+				MIMEType: mimeType,
 				Detail:   schema.ImageURLDetailAuto,
 			},
 		},
@@ -58,9 +96,45 @@ func main() {
 		userMsg,
 	}
 
-	resp, err := chat.Generate(ctx, msgs)
+	// response_format 是厂商专有参数，缓存键里看不到；用它的哈希作 salt，Schema 改动后旧结果不再命中
+	salt, err := responseFormatSalt(cfg.ResponseFormat)
+	if err != nil {
+		log.Fatalf("计算缓存 salt 失败: %v", err)
+	}
+	resp, err := cache.Generate(ctx, chat, cfg.Model, msgs, visioncache.WithSalt(salt))
 	if err != nil {
 		log.Fatalf("生成失败: %v", err)
 	}
-	fmt.Println("🖼️ 本地文件模式结果：\n", resp.Content)
+	fmt.Println("🗄️ 视觉缓存：", cache.Stats())
+
+	var report regionReport
+	if err := json.Unmarshal([]byte(llm.TrimJSONFence(resp.Content)), &report); err != nil {
+		log.Fatalf("解析模型输出失败: %v\n原始输出：%s", err, resp.Content)
+	}
+
+	regions, problems := validateRegions(report.Regions, width, height)
+	for _, p := range problems {
+		log.Println("⚠️", p)
+	}
+
+	fmt.Println("🖼️ 本地文件模式结果：\n", report.Summary)
+	for i, r := range regions {
+		fmt.Printf("%d. [%s] (%d,%d)-(%d,%d) %s\n", i+1, r.Label, r.X1, r.Y1, r.X2, r.Y2, r.Issue)
+	}
+
+	outPath, err := saveAnnotated(*imagePath, renderAnnotated(src, regions))
+	if err != nil {
+		log.Fatalf("保存标注图片失败: %v", err)
+	}
+	fmt.Println("📌 标注图片已保存：", outPath)
+}
+
+// 按 response_format 的完整内容（名称、Schema、strict 等）生成缓存 salt
+func responseFormatSalt(format *ark.ResponseFormat) (string, error) {
+	raw, err := json.Marshal(format)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return "response_format:" + hex.EncodeToString(sum[:8]), nil
 }
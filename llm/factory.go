@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"agent-demo/ratelimit"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/eino-contrib/jsonschema"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// 支持的模型服务
+const (
+	ProviderArk    = "ark"    // 火山方舟
+	ProviderOpenAI = "openai" // 任意 OpenAI 兼容服务（OpenAI、DeepSeek、vLLM、Ollama 等）
+	ProviderMock   = "mock"   // 内置的脚本化模型，不访问网络
+)
+
+// OpenAI 兼容服务未指定地址时使用的默认地址
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// 按服务名创建模型所需的配置；不同服务只用到其中的一部分
+type Config struct {
+	Provider string
+	BaseURL  string
+	APIKey   string
+	Model    string
+	// 结构化输出格式，ark 与 openai 生效（openai 会转换为自己的类型），mock 忽略（由脚本决定回复）
+	ResponseFormat *ark.ResponseFormat
+	// 限流配置，mock 同样生效，便于离线演示排队
+	RateLimit ratelimit.Config
+	// mock 的脚本文件（YAML 或 JSON），为空时按内置规则回显用户输入；相对路径的解析见 ResolveScriptPath
+	MockScript string
+}
+
+func (c Config) String() string {
+	if c.Provider == ProviderMock {
+		if c.MockScript != "" {
+			return fmt.Sprintf("mock（脚本 %s）", c.MockScript)
+		}
+		return "mock"
+	}
+	return fmt.Sprintf("%s %s @ %s", c.Provider, c.Model, c.BaseURL)
+}
+
+// 从环境变量读取配置，供各个 Demo 共用；模型 ID 可以为空（如模型路由器按 profile 另行指定），创建模型时再检查：
+//
+//	LLM_PROVIDER                      ark / openai / mock；不设置时有 ARK_API_KEY 用 ark，有 OPENAI_API_KEY 用 openai，否则用 mock
+//	ARK_API_KEY / ARK_BASE_URL / ARK_MODEL
+//	OPENAI_API_KEY / OPENAI_BASE_URL / OPENAI_MODEL
+//	MOCK_SCRIPT                       mock 的脚本文件，相对路径按模块根目录或可执行文件目录解析
+//	ARK_RPM / ARK_TPM                 限流，见 ratelimit.ConfigFromEnv
+func ConfigFromEnv() (Config, error) {
+	limit, err := ratelimit.ConfigFromEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	cfg := Config{Provider: strings.ToLower(strings.TrimSpace(os.Getenv("LLM_PROVIDER"))), RateLimit: limit}
+	if cfg.Provider == "" {
+		switch {
+		case os.Getenv("ARK_API_KEY") != "":
+			cfg.Provider = ProviderArk
+		case os.Getenv("OPENAI_API_KEY") != "":
+			cfg.Provider = ProviderOpenAI
+		default:
+			cfg.Provider = ProviderMock
+			log.Printf("🧪 未设置 ARK_API_KEY / OPENAI_API_KEY，使用离线 mock 模型（LLM_PROVIDER=mock）")
+		}
+	}
+
+	switch cfg.Provider {
+	case ProviderArk:
+		cfg.APIKey, cfg.BaseURL, cfg.Model = os.Getenv("ARK_API_KEY"), os.Getenv("ARK_BASE_URL"), os.Getenv("ARK_MODEL")
+		if cfg.APIKey == "" || cfg.BaseURL == "" {
+			return cfg, fmt.Errorf("使用 ark 需要设置 ARK_API_KEY / ARK_BASE_URL 环境变量（或设置 LLM_PROVIDER=mock 离线运行）")
+		}
+	case ProviderOpenAI:
+		cfg.APIKey, cfg.BaseURL, cfg.Model = os.Getenv("OPENAI_API_KEY"), os.Getenv("OPENAI_BASE_URL"), os.Getenv("OPENAI_MODEL")
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = defaultOpenAIBaseURL
+		}
+	case ProviderMock:
+		cfg.Model = os.Getenv("ARK_MODEL")
+		if cfg.Model == "" {
+			cfg.Model = "mock"
+		}
+		cfg.MockScript = os.Getenv("MOCK_SCRIPT")
+	default:
+		return cfg, fmt.Errorf("不支持的 LLM_PROVIDER %q，可选 ark / openai / mock", cfg.Provider)
+	}
+	return cfg, nil
+}
+
+// 按 cfg.Provider 创建带限流的模型
+func NewChatModel(ctx context.Context, cfg Config) (*ratelimit.ChatModel, error) {
+	return NewChatModelWithLimiter(ctx, cfg, ratelimit.NewLimiter(cfg.RateLimit))
+}
+
+// 与 NewChatModel 相同，但使用给定的限流器，便于多个实例共享同一模型的额度（此时忽略 cfg.RateLimit）
+func NewChatModelWithLimiter(ctx context.Context, cfg Config, limiter *ratelimit.Limiter) (*ratelimit.ChatModel, error) {
+	switch cfg.Provider {
+	case ProviderArk, ProviderOpenAI:
+		if cfg.Model == "" {
+			return nil, fmt.Errorf("未指定 %s 的模型 ID（ARK_MODEL / OPENAI_MODEL）", cfg.Provider)
+		}
+		if cfg.Provider == ProviderArk {
+			return ratelimit.NewArkChatModelWithLimiter(ctx, &ark.ChatModelConfig{
+				BaseURL:        cfg.BaseURL,
+				APIKey:         cfg.APIKey,
+				Model:          cfg.Model,
+				ResponseFormat: cfg.ResponseFormat,
+			}, limiter)
+		}
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = defaultOpenAIBaseURL
+		}
+		format, err := openAIResponseFormat(cfg.ResponseFormat)
+		if err != nil {
+			return nil, err
+		}
+		return ratelimit.NewOpenAIChatModelWithLimiter(ctx, &openai.ChatModelConfig{
+			BaseURL:        cfg.BaseURL,
+			APIKey:         cfg.APIKey,
+			Model:          cfg.Model,
+			ResponseFormat: format,
+		}, limiter)
+	case ProviderMock:
+		script := &Script{}
+		if cfg.MockScript != "" {
+			var err error
+			if script, err = LoadScript(cfg.MockScript); err != nil {
+				return nil, err
+			}
+		}
+		m, err := NewMockChatModel(cfg.Model, script)
+		if err != nil {
+			return nil, err
+		}
+		return ratelimit.Wrap(m, limiter), nil
+	default:
+		return nil, fmt.Errorf("不支持的模型服务 %q，可选 ark / openai / mock", cfg.Provider)
+	}
+}
+
+// 把方舟格式的结构化输出声明转换为 OpenAI 客户端的类型，两者的请求字段一致
+func openAIResponseFormat(f *ark.ResponseFormat) (*openai.ChatCompletionResponseFormat, error) {
+	if f == nil {
+		return nil, nil
+	}
+	out := &openai.ChatCompletionResponseFormat{}
+	switch f.Type {
+	case arkmodel.ResponseFormatJSONSchema:
+		out.Type = openai.ChatCompletionResponseFormatTypeJSONSchema
+	case arkmodel.ResponseFormatJsonObject:
+		out.Type = openai.ChatCompletionResponseFormatTypeJSONObject
+	case arkmodel.ResponseFormatText:
+		out.Type = openai.ChatCompletionResponseFormatTypeText
+	default:
+		return nil, fmt.Errorf("不支持的 response_format 类型 %q", f.Type)
+	}
+	if f.JSONSchema != nil {
+		// schema 可能是 map 或任意可序列化的结构，经 JSON 中转为客户端要求的类型
+		raw, err := json.Marshal(f.JSONSchema.Schema)
+		if err != nil {
+			return nil, fmt.Errorf("序列化 response_format 的 schema 失败: %w", err)
+		}
+		schema := &jsonschema.Schema{}
+		if err := json.Unmarshal(raw, schema); err != nil {
+			return nil, fmt.Errorf("解析 response_format 的 schema 失败: %w", err)
+		}
+		out.JSONSchema = &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:        f.JSONSchema.Name,
+			Description: f.JSONSchema.Description,
+			JSONSchema:  schema,
+			Strict:      f.JSONSchema.Strict,
+		}
+	}
+	return out, nil
+}
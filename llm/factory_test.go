@@ -0,0 +1,75 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/schema"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// openai 走 OpenAI 客户端：请求发到配置的地址，带上转换后的 response_format，429 时按 Retry-After 重试
+func TestOpenAIProvider(t *testing.T) {
+	var calls atomic.Int32
+	var body map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("请求路径 = %s", r.URL.Path)
+		}
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"error":{"message":"rate limited","type":"rate_limit"}}`)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("请求体不是 JSON: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","object":"chat.completion","model":"gpt-test","choices":[{"index":0,"message":{"role":"assistant","content":"{\"ok\":true}"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}`)
+	}))
+	defer srv.Close()
+
+	m, err := NewChatModel(context.Background(), Config{
+		Provider: ProviderOpenAI,
+		BaseURL:  srv.URL + "/v1",
+		APIKey:   "test",
+		Model:    "gpt-test",
+		ResponseFormat: &ark.ResponseFormat{
+			Type: arkmodel.ResponseFormatJSONSchema,
+			JSONSchema: &arkmodel.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:   "result",
+				Schema: map[string]any{"type": "object", "properties": map[string]any{"ok": map[string]any{"type": "boolean"}}},
+				Strict: true,
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := m.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"ok":true}` {
+		t.Errorf("回复 = %q", resp.Content)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("请求次数 = %d，期望 429 后重试一次", calls.Load())
+	}
+	format, _ := body["response_format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Fatalf("response_format = %v", body["response_format"])
+	}
+	js, _ := format["json_schema"].(map[string]any)
+	if js["name"] != "result" || js["schema"] == nil {
+		t.Errorf("json_schema = %v", js)
+	}
+}
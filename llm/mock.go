@@ -0,0 +1,375 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"gopkg.in/yaml.v3"
+)
+
+// mock 模型的脚本：规则自上而下匹配，第一条命中的给出回复；都不命中时回显最后一条消息
+type Script struct {
+	Rules []*MockRule `yaml:"rules" json:"rules"`
+	// 流式输出时每个 chunk 的字符数与间隔，模拟打字机效果
+	ChunkRunes   int `yaml:"chunk_runes" json:"chunk_runes"`
+	ChunkDelayMS int `yaml:"chunk_delay_ms" json:"chunk_delay_ms"`
+}
+
+// 一条规则内的各项条件是“且”的关系
+type MockRule struct {
+	// 只对该模型 ID 生效，空表示全部
+	Model string `yaml:"model" json:"model"`
+	// 最后一条消息的角色（user / assistant / tool / system），空表示不限
+	Role string `yaml:"role" json:"role"`
+	// 系统提示词包含该文本，用于区分同一模型扮演的不同角色
+	System string `yaml:"system" json:"system"`
+	// 最后一条消息包含该文本（不区分大小写）
+	Match string `yaml:"match" json:"match"`
+	Regex string `yaml:"regex" json:"regex"`
+
+	// 回复正文，{{input}} 替换为最后一条消息的文本
+	Content   string         `yaml:"content" json:"content"`
+	Reasoning string         `yaml:"reasoning" json:"reasoning"`
+	ToolCalls []MockToolCall `yaml:"tool_calls" json:"tool_calls"`
+	// 不为空时返回该错误，用于演示回退、熔断等
+	Error   string `yaml:"error" json:"error"`
+	DelayMS int    `yaml:"delay_ms" json:"delay_ms"`
+
+	compiled *regexp.Regexp
+}
+
+type MockToolCall struct {
+	Name      string `yaml:"name" json:"name"`
+	Arguments any    `yaml:"arguments" json:"arguments"` // 任意结构，调用时序列化为 JSON
+}
+
+// 读取脚本文件，按扩展名区分 YAML 与 JSON，未知字段视为错误；相对路径的解析见 ResolveScriptPath
+func LoadScript(path string) (*Script, error) {
+	path, err := ResolveScriptPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &Script{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(s)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(s)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 mock 脚本 %s 失败: %w", path, err)
+	}
+	if err := s.validate(); err != nil {
+		return nil, fmt.Errorf("mock 脚本 %s 校验失败: %w", path, err)
+	}
+	return s, nil
+}
+
+// ResolveScriptPath 定位脚本文件：绝对路径原样使用；相对路径先按当前工作目录查找（用户自己的 MOCK_SCRIPT），
+// 找不到时再按模块根目录（go run / go test 时即源码目录）和可执行文件所在目录查找，供各 Demo 的内置默认脚本使用
+func ResolveScriptPath(path string) (string, error) {
+	if filepath.IsAbs(path) {
+		return path, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return filepath.Abs(path)
+	}
+	tried := []string{path}
+	for _, dir := range scriptSearchDirs() {
+		candidate := filepath.Join(dir, path)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+		tried = append(tried, candidate)
+	}
+	return "", fmt.Errorf("找不到 mock 脚本 %s（已查找 %s），也可以改用绝对路径", path, strings.Join(tried, "、"))
+}
+
+func scriptSearchDirs() []string {
+	var dirs []string
+	// 本文件位于 <模块根目录>/llm；使用 -trimpath 构建时拿不到绝对路径，只能依赖可执行文件目录
+	if _, file, _, ok := runtime.Caller(0); ok && filepath.IsAbs(file) {
+		dirs = append(dirs, filepath.Dir(filepath.Dir(file)))
+	}
+	if exe, err := os.Executable(); err == nil {
+		if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+			exe = resolved
+		}
+		dirs = append(dirs, filepath.Dir(exe))
+	}
+	return dirs
+}
+
+func (s *Script) validate() error {
+	var errs []error
+	for i, rule := range s.Rules {
+		switch schema.RoleType(rule.Role) {
+		case "", schema.User, schema.Assistant, schema.Tool, schema.System:
+		default:
+			errs = append(errs, fmt.Errorf("rules[%d].role 只能是 user / assistant / tool / system", i))
+		}
+		if rule.Regex != "" {
+			re, err := regexp.Compile(rule.Regex)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rules[%d].regex: %w", i, err))
+			}
+			rule.compiled = re
+		}
+		for j, call := range rule.ToolCalls {
+			if call.Name == "" {
+				errs = append(errs, fmt.Errorf("rules[%d].tool_calls[%d] 缺少 name", i, j))
+			}
+			if _, err := json.Marshal(call.Arguments); err != nil {
+				errs = append(errs, fmt.Errorf("rules[%d].tool_calls[%d].arguments 无法序列化为 JSON: %w", i, j, err))
+			}
+		}
+	}
+	if s.ChunkRunes <= 0 {
+		s.ChunkRunes = 4
+	}
+	if s.ChunkDelayMS < 0 {
+		errs = append(errs, errors.New("chunk_delay_ms 不能为负数"))
+	}
+	return errors.Join(errs...)
+}
+
+// MockChatModel 按脚本回复，不访问网络；实现了 ToolCallingChatModel，可替换任意 Demo 中的模型
+type MockChatModel struct {
+	modelID string
+	script  *Script
+	tools   []*schema.ToolInfo
+	calls   *atomic.Int64 // 生成工具调用 ID，WithTools 派生的实例共享
+}
+
+var _ model.ToolCallingChatModel = (*MockChatModel)(nil)
+
+// 脚本在创建时校验；传 nil 等同于空脚本
+func NewMockChatModel(modelID string, script *Script) (*MockChatModel, error) {
+	if script == nil {
+		script = &Script{}
+	}
+	if err := script.validate(); err != nil {
+		return nil, fmt.Errorf("mock 脚本无效: %w", err)
+	}
+	return &MockChatModel{modelID: modelID, script: script, calls: &atomic.Int64{}}, nil
+}
+
+func (m *MockChatModel) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	rule, err := m.respond(ctx, in, opts)
+	if err != nil {
+		return nil, err
+	}
+	return m.reply(rule, in, opts), nil
+}
+
+// 把回复正文按 ChunkRunes 切块输出，推理内容在前，工具调用与用量放在最后一个 chunk
+func (m *MockChatModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	rule, err := m.respond(ctx, in, opts)
+	if err != nil {
+		return nil, err
+	}
+	full := m.reply(rule, in, opts)
+	sr, sw := schema.Pipe[*schema.Message](4)
+	go func() {
+		defer sw.Close()
+		delay := time.Duration(m.script.ChunkDelayMS) * time.Millisecond
+		send := func(chunk *schema.Message) bool {
+			if delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					sw.Send(nil, ctx.Err())
+					return false
+				}
+			}
+			return !sw.Send(chunk, nil)
+		}
+		for _, piece := range splitRunes(full.ReasoningContent, m.script.ChunkRunes) {
+			if !send(&schema.Message{Role: schema.Assistant, ReasoningContent: piece}) {
+				return
+			}
+		}
+		for _, piece := range splitRunes(full.Content, m.script.ChunkRunes) {
+			if !send(&schema.Message{Role: schema.Assistant, Content: piece}) {
+				return
+			}
+		}
+		send(&schema.Message{Role: schema.Assistant, ToolCalls: full.ToolCalls, ResponseMeta: full.ResponseMeta})
+	}()
+	return sr, nil
+}
+
+func (m *MockChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	cp := *m
+	cp.tools = tools
+	return &cp, nil
+}
+
+func (m *MockChatModel) GetType() string {
+	return "Mock"
+}
+
+// 选出命中的规则并模拟延迟与错误；没有命中时返回 nil，由 reply 回显
+func (m *MockChatModel) respond(ctx context.Context, in []*schema.Message, opts []model.Option) (*MockRule, error) {
+	if len(in) == 0 {
+		return nil, errors.New("mock: 消息列表为空")
+	}
+	rule := m.match(in, opts)
+	if rule == nil {
+		return nil, ctx.Err()
+	}
+	if rule.DelayMS > 0 {
+		select {
+		case <-time.After(time.Duration(rule.DelayMS) * time.Millisecond):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if rule.Error != "" {
+		return nil, fmt.Errorf("mock %s: %s", m.modelID, rule.Error)
+	}
+	return rule, ctx.Err()
+}
+
+func (m *MockChatModel) match(in []*schema.Message, opts []model.Option) *MockRule {
+	last := in[len(in)-1]
+	text := strings.ToLower(messageText(last))
+	var system []string
+	for _, msg := range in {
+		if msg.Role == schema.System {
+			system = append(system, msg.Content)
+		}
+	}
+	prompt := strings.ToLower(strings.Join(system, "\n"))
+	tools := m.boundTools(opts)
+	for _, rule := range m.script.Rules {
+		if rule.Model != "" && rule.Model != m.modelID {
+			continue
+		}
+		if rule.Role != "" && rule.Role != string(last.Role) {
+			continue
+		}
+		if rule.System != "" && !strings.Contains(prompt, strings.ToLower(rule.System)) {
+			continue
+		}
+		if rule.Match != "" && !strings.Contains(text, strings.ToLower(rule.Match)) {
+			continue
+		}
+		if rule.compiled != nil && !rule.compiled.MatchString(messageText(last)) {
+			continue
+		}
+		// 脚本里的工具没有绑定时跳过该规则，避免调用方收到不认识的工具调用
+		usable := true
+		for _, call := range rule.ToolCalls {
+			if !tools[call.Name] {
+				usable = false
+				break
+			}
+		}
+		if usable {
+			return rule
+		}
+	}
+	return nil
+}
+
+// 绑定的工具以调用参数中的为准（与 ark 一致），否则用 WithTools 绑定的
+func (m *MockChatModel) boundTools(opts []model.Option) map[string]bool {
+	tools := m.tools
+	if o := model.GetCommonOptions(&model.Options{}, opts...); o.Tools != nil {
+		tools = o.Tools
+	}
+	names := make(map[string]bool, len(tools))
+	for _, t := range tools {
+		names[t.Name] = true
+	}
+	return names
+}
+
+func (m *MockChatModel) reply(rule *MockRule, in []*schema.Message, opts []model.Option) *schema.Message {
+	input := messageText(in[len(in)-1])
+	resp := &schema.Message{Role: schema.Assistant}
+	if rule == nil {
+		resp.Content = fmt.Sprintf("【mock:%s】已收到：%s", m.modelID, input)
+	} else {
+		resp.Content = strings.ReplaceAll(rule.Content, "{{input}}", input)
+		resp.ReasoningContent = rule.Reasoning
+		for i, call := range rule.ToolCalls {
+			args, _ := json.Marshal(call.Arguments)
+			if call.Arguments == nil {
+				args = []byte("{}")
+			}
+			index := i
+			resp.ToolCalls = append(resp.ToolCalls, schema.ToolCall{
+				Index:    &index,
+				ID:       fmt.Sprintf("call_mock_%d", m.calls.Add(1)),
+				Type:     "function",
+				Function: schema.FunctionCall{Name: call.Name, Arguments: string(args)},
+			})
+		}
+	}
+
+	// MaxTokens 按字符截断，便于演示 length 结束
+	finish := "stop"
+	if o := model.GetCommonOptions(&model.Options{}, opts...); o.MaxTokens != nil && utf8.RuneCountInString(resp.Content) > *o.MaxTokens {
+		resp.Content = string([]rune(resp.Content)[:*o.MaxTokens])
+		finish = "length"
+	}
+	if len(resp.ToolCalls) > 0 {
+		finish = "tool_calls"
+	}
+	prompt := 0
+	for _, msg := range in {
+		prompt += utf8.RuneCountInString(messageText(msg))
+	}
+	completion := utf8.RuneCountInString(resp.Content) + utf8.RuneCountInString(resp.ReasoningContent)
+	for _, call := range resp.ToolCalls {
+		completion += utf8.RuneCountInString(call.Function.Arguments)
+	}
+	resp.ResponseMeta = &schema.ResponseMeta{
+		FinishReason: finish,
+		Usage:        &schema.TokenUsage{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+	}
+	return resp
+}
+
+func messageText(msg *schema.Message) string {
+	texts := []string{msg.Content}
+	for _, part := range msg.MultiContent {
+		if part.Type == schema.ChatMessagePartTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
+
+func splitRunes(s string, n int) []string {
+	runes := []rune(s)
+	var out []string
+	for len(runes) > 0 {
+		k := min(n, len(runes))
+		out = append(out, string(runes[:k]))
+		runes = runes[k:]
+	}
+	return out
+}
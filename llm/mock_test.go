@@ -0,0 +1,226 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestMockRuleMatching(t *testing.T) {
+	weather := []*schema.ToolInfo{{Name: "get_weather"}}
+	script := &Script{Rules: []*MockRule{
+		{Model: "pro", Content: "pro 模型"},
+		{Role: "tool", Content: "工具结果：{{input}}"},
+		{System: "翻译助手", Content: "translated"},
+		{Match: "天气", ToolCalls: []MockToolCall{{Name: "get_weather", Arguments: map[string]any{"city": "北京"}}}},
+		{Regex: `^\d+\s*\+\s*\d+$`, Content: "算式"},
+		{Match: "HELLO", Content: "hi"},
+	}}
+
+	cases := []struct {
+		name      string
+		modelID   string
+		msgs      []*schema.Message
+		opts      []model.Option
+		content   string
+		toolCalls []string
+	}{
+		{
+			name:    "model 只对指定模型生效",
+			modelID: "pro",
+			msgs:    []*schema.Message{schema.UserMessage("随便问问")},
+			content: "pro 模型",
+		},
+		{
+			name:    "role 匹配最后一条消息的角色，{{input}} 替换为其文本",
+			modelID: "lite",
+			msgs:    []*schema.Message{schema.UserMessage("查天气"), schema.ToolMessage("晴", "call_1")},
+			content: "工具结果：晴",
+		},
+		{
+			name:    "system 匹配系统提示词",
+			modelID: "lite",
+			msgs:    []*schema.Message{schema.SystemMessage("你是翻译助手"), schema.UserMessage("你好")},
+			content: "translated",
+		},
+		{
+			name:      "绑定了工具时返回工具调用",
+			modelID:   "lite",
+			msgs:      []*schema.Message{schema.UserMessage("北京天气如何")},
+			opts:      []model.Option{model.WithTools(weather)},
+			toolCalls: []string{"get_weather"},
+		},
+		{
+			name:    "工具未绑定时跳过该规则，落到回显",
+			modelID: "lite",
+			msgs:    []*schema.Message{schema.UserMessage("北京天气如何")},
+			content: "【mock:lite】已收到：北京天气如何",
+		},
+		{
+			name:    "regex 按原文匹配",
+			modelID: "lite",
+			msgs:    []*schema.Message{schema.UserMessage("1 + 2")},
+			content: "算式",
+		},
+		{
+			name:    "match 不区分大小写",
+			modelID: "lite",
+			msgs:    []*schema.Message{schema.UserMessage("hello there")},
+			content: "hi",
+		},
+		{
+			name:    "都不命中时回显",
+			modelID: "lite",
+			msgs:    []*schema.Message{schema.UserMessage("其它问题")},
+			content: "【mock:lite】已收到：其它问题",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewMockChatModel(tc.modelID, script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := m.Generate(context.Background(), tc.msgs, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != tc.content {
+				t.Errorf("Content = %q，期望 %q", resp.Content, tc.content)
+			}
+			var names []string
+			for _, call := range resp.ToolCalls {
+				names = append(names, call.Function.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tc.toolCalls, ",") {
+				t.Errorf("ToolCalls = %v，期望 %v", names, tc.toolCalls)
+			}
+			if len(resp.ToolCalls) > 0 && resp.ToolCalls[0].Function.Arguments != `{"city":"北京"}` {
+				t.Errorf("Arguments = %s", resp.ToolCalls[0].Function.Arguments)
+			}
+		})
+	}
+}
+
+func TestMockErrorAndDelay(t *testing.T) {
+	m, err := NewMockChatModel("m", &Script{Rules: []*MockRule{
+		{Match: "fail", Error: "上游 503"},
+		{Match: "slow", DelayMS: 50, Content: "done"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Generate(context.Background(), []*schema.Message{schema.UserMessage("fail")}); err == nil || !strings.Contains(err.Error(), "上游 503") {
+		t.Errorf("error 规则应返回脚本中的错误，实际为 %v", err)
+	}
+	if _, err := m.Stream(context.Background(), []*schema.Message{schema.UserMessage("fail")}); err == nil {
+		t.Error("流式调用同样应返回脚本中的错误")
+	}
+
+	start := time.Now()
+	resp, err := m.Generate(context.Background(), []*schema.Message{schema.UserMessage("slow")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || resp.Content != "done" {
+		t.Errorf("delay_ms 未生效：耗时 %s，回复 %q", elapsed, resp.Content)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.Generate(ctx, []*schema.Message{schema.UserMessage("slow")}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("延迟期间 ctx 超时应返回 DeadlineExceeded，实际为 %v", err)
+	}
+}
+
+func TestMockStreamChunks(t *testing.T) {
+	m, err := NewMockChatModel("m", &Script{ChunkRunes: 2, Rules: []*MockRule{{Content: "一二三四五", Reasoning: "想想"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sr, err := m.Stream(context.Background(), []*schema.Message{schema.UserMessage("hi")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []*schema.Message
+	for {
+		chunk, err := sr.Recv()
+		if err != nil {
+			break
+		}
+		chunks = append(chunks, chunk)
+	}
+	full, err := schema.ConcatMessages(chunks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 推理 1 块、正文 3 块、结尾带用量的 1 块
+	if len(chunks) != 5 || full.Content != "一二三四五" || full.ReasoningContent != "想想" {
+		t.Errorf("收到 %d 个 chunk，拼接结果 %q / %q", len(chunks), full.ReasoningContent, full.Content)
+	}
+	if full.ResponseMeta == nil || full.ResponseMeta.Usage == nil {
+		t.Error("最后一个 chunk 应带用量")
+	}
+}
+
+func TestNewMockChatModelRejectsInvalidScript(t *testing.T) {
+	_, err := NewMockChatModel("m", &Script{Rules: []*MockRule{
+		{Role: "robot"},
+		{Regex: "("},
+		{ToolCalls: []MockToolCall{{}}},
+	}})
+	if err == nil {
+		t.Fatal("无效脚本应返回错误")
+	}
+	for _, want := range []string{"rules[0].role", "rules[1].regex", "rules[2].tool_calls[0]"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息缺少 %s：%v", want, err)
+		}
+	}
+}
+
+func TestResolveScriptPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	// 工作目录下没有时回退到模块根目录，Demo 的默认脚本在任意目录下都能找到
+	path, err := ResolveScriptPath("tools/mock.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil || !filepath.IsAbs(path) || strings.HasPrefix(path, dir) {
+		t.Errorf("解析结果 %s 应为模块根目录下的脚本: %v", path, err)
+	}
+	if _, err := LoadScript("tools/mock.yaml"); err != nil {
+		t.Errorf("切换工作目录后加载失败: %v", err)
+	}
+
+	// 用户在自己目录下写的相对路径优先，同名时也不会被模块里的脚本顶替
+	for _, rel := range []string{"my.yaml", filepath.Join("tools", "mock.yaml")} {
+		if err := os.MkdirAll(filepath.Dir(rel), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(rel, []byte("rules: []\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		path, err := ResolveScriptPath("./" + rel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join(dir, rel); path != want {
+			t.Errorf("ResolveScriptPath(%q) = %s，期望 %s", rel, path, want)
+		}
+	}
+
+	if _, err := ResolveScriptPath("no/such/mock.yaml"); err == nil {
+		t.Error("不存在的脚本应返回错误")
+	}
+}
@@ -0,0 +1,12 @@
+package llm
+
+import "strings"
+
+// TrimJSONFence 去掉回复外层的 ```json 代码块：部分模型即使要求输出 JSON 也会包一层
+func TrimJSONFence(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "```json")
+	s = strings.TrimPrefix(s, "```")
+	s = strings.TrimSuffix(s, "```")
+	return strings.TrimSpace(s)
+}
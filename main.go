@@ -4,11 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"os"
 	"strings"
 	"time"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
+	"agent-demo/llm"
+
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 )
 
@@ -16,7 +17,7 @@ import (
 type Agent struct {
 	name   string
 	system string
-	model  *ark.ChatModel
+	model  model.BaseChatModel
 }
 
 func (a *Agent) Act(ctx context.Context, history []*schema.Message, userHint string) (string, error) {
@@ -36,17 +37,15 @@ func (a *Agent) Act(ctx context.Context, history []*schema.Message, userHint str
 func main() {
 	ctx := context.Background()
 
-	apiKey, baseURL, modelID := os.Getenv("ARK_API_KEY"), os.Getenv("ARK_BASE_URL"), os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL")
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	chat, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL,
-		APIKey:  apiKey,
-		Model:   modelID,
-		// 可选：增加超时/重试等
-	})
+	if cfg.Provider == llm.ProviderMock && cfg.MockScript == "" {
+		// 与 mulit_agent 是同一个三代理协作流程，共用离线脚本
+		cfg.MockScript = "mulit_agent/mock.yaml"
+	}
+	chat, err := llm.NewChatModel(ctx, cfg)
 	if err != nil {
 		log.Fatal("init model:", err)
 	}
@@ -5,11 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"sync/atomic"
 	"time"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
+	"agent-demo/agent"
+	"agent-demo/llm"
+
 	"github.com/cloudwego/eino/schema"
 	"github.com/gorilla/websocket"
 )
@@ -79,12 +80,14 @@ func toBytes(m map[string]json.RawMessage) []byte { b, _ := json.Marshal(m); ret
 
 func main() {
 	ctx := context.Background()
-	apiKey, baseURL, modelID := os.Getenv("ARK_API_KEY"), os.Getenv("ARK_BASE_URL"), os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("缺少 ARK_* 环境变量")
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	chat, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{BaseURL: baseURL, APIKey: apiKey, Model: modelID})
+	if cfg.Provider == llm.ProviderMock && cfg.MockScript == "" {
+		cfg.MockScript = "mcp_demo/host/mock.yaml"
+	}
+	chat, err := llm.NewChatModel(ctx, cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -110,11 +113,15 @@ func main() {
 	}
 
 	// 1) 常规一问一答：模型若需要时间，会触发 tool call
-	user := schema.UserMessage("请告诉我现在的时间，并加上一句问候。必要时请调用工具 getTime。")
-	resp, err := toolChat.Generate(ctx, []*schema.Message{
+	conv, err := agent.NewConversation(
 		schema.SystemMessage("你是智能助手。需要当前时间时请调用工具 getTime。"),
-		user,
-	})
+		schema.UserMessage("请告诉我现在的时间，并加上一句问候。必要时请调用工具 getTime。"),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	msgs, _ := conv.Messages()
+	resp, err := toolChat.Generate(ctx, msgs)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -122,28 +129,34 @@ func main() {
 	if len(resp.ToolCalls) == 0 {
 		fmt.Println("模型直接回答：", resp.Content)
 	} else {
+		// 先追加带 ToolCalls 的助手消息，再按调用 ID 逐个回灌工具结果
+		if err := conv.Append(resp); err != nil {
+			log.Fatal(err)
+		}
 		for _, call := range resp.ToolCalls {
-			if call.Function.Name != "getTime" {
-				continue
-			}
-			// 调 WS JSON-RPC
-			res, err := client.call("getTime", map[string]any{})
-			if err != nil {
-				log.Println("rpc getTime:", err)
-				res = map[string]any{"now": "调用失败"}
+			res := map[string]any{"error": "未知工具 " + call.Function.Name}
+			if call.Function.Name == "getTime" {
+				// 调 WS JSON-RPC
+				res, err = client.call("getTime", map[string]any{})
+				if err != nil {
+					log.Println("rpc getTime:", err)
+					res = map[string]any{"now": "调用失败"}
+				}
 			}
-
-			// 回灌工具结果
-			final, err := toolChat.Generate(ctx, []*schema.Message{
-				schema.SystemMessage("你是智能助手"),
-				user,
-				schema.ToolMessage(mustJSON(res), call.ID, schema.WithToolName(call.Function.Name)),
-			})
-			if err != nil {
+			if err := conv.AddToolOutput(call.ID, mustJSON(res)); err != nil {
 				log.Fatal(err)
 			}
-			fmt.Println("最终回答：", final.Content)
 		}
+
+		msgs, err := conv.Messages()
+		if err != nil {
+			log.Fatal(err)
+		}
+		final, err := toolChat.Generate(ctx, msgs)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("最终回答：", final.Content)
 	}
 
 	// 2) 体现“双向事件”：Server 每 5 秒推 onTick，Host 收到后再触发一次模型推理
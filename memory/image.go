@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent-demo/visioncache"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 历史中只保存图片引用（file://绝对路径），发送前才展开为 base64，避免会话里堆积大段内联数据
+const imageRefScheme = "file://"
+
+// 每个会话最多保留的原图数量，更早的图片会被替换为文字描述
+const keepRecentImages = 1
+
+func newImagePart(path string) (schema.ChatMessagePart, error) {
+	abs, err := filepath.Abs(strings.TrimSpace(path))
+	if err != nil {
+		return schema.ChatMessagePart{}, err
+	}
+	mimeType, err := detectImageMIME(abs)
+	if err != nil {
+		return schema.ChatMessagePart{}, err
+	}
+	return schema.ChatMessagePart{
+		Type: schema.ChatMessagePartTypeImageURL,
+		ImageURL: &schema.ChatMessageImageURL{
+			URL:      imageRefScheme + abs,
+			MIMEType: mimeType,
+			Detail:   schema.ImageURLDetailAuto,
+		},
+	}, nil
+}
+
+func detectImageMIME(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, _ := f.Read(head)
+	mimeType := http.DetectContentType(head[:n])
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("%s 不是图片文件（%s）", path, mimeType)
+	}
+	return mimeType, nil
+}
+
+func imageRefPath(part schema.ChatMessagePart) (string, bool) {
+	if part.Type != schema.ChatMessagePartTypeImageURL || part.ImageURL == nil {
+		return "", false
+	}
+	if !strings.HasPrefix(part.ImageURL.URL, imageRefScheme) {
+		return "", false
+	}
+	return strings.TrimPrefix(part.ImageURL.URL, imageRefScheme), true
+}
+
+// 复制消息列表，把图片引用展开为 data URL；原历史保持引用不变。
+// 读不到的图片（被移动或删除）替换为文字占位并记录日志，不影响本轮对话
+func expandImageRefs(msgs []*schema.Message) []*schema.Message {
+	out := make([]*schema.Message, len(msgs))
+	for i, msg := range msgs {
+		if len(msg.MultiContent) == 0 {
+			out[i] = msg
+			continue
+		}
+		cp := *msg
+		cp.MultiContent = make([]schema.ChatMessagePart, len(msg.MultiContent))
+		for j, part := range msg.MultiContent {
+			path, ok := imageRefPath(part)
+			if !ok {
+				cp.MultiContent[j] = part
+				continue
+			}
+			inlined, err := inlineImage(part)
+			if err != nil {
+				log.Printf("⚠️ %v，改用文字占位", err)
+				inlined = missingImagePart(path)
+			}
+			cp.MultiContent[j] = inlined
+		}
+		out[i] = &cp
+	}
+	return out
+}
+
+// 读取引用的图片文件并内联为 data URL
+func inlineImage(part schema.ChatMessagePart) (schema.ChatMessagePart, error) {
+	path, _ := imageRefPath(part)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return part, fmt.Errorf("读取图片 %s 失败: %w", path, err)
+	}
+	img := *part.ImageURL
+	img.URL = fmt.Sprintf("data:%s;base64,%s", img.MIMEType, base64.StdEncoding.EncodeToString(data))
+	part.ImageURL = &img
+	return part, nil
+}
+
+func missingImagePart(path string) schema.ChatMessagePart {
+	return schema.ChatMessagePart{
+		Type: schema.ChatMessagePartTypeText,
+		Text: fmt.Sprintf("[图片 %s 已无法读取，内容不可用]", filepath.Base(path)),
+	}
+}
+
+// 图片描述器：描述结果按图片内容缓存，同一张图只描述一次
+type ImageDescriber struct {
+	model   model.BaseChatModel
+	modelID string
+	cache   *visioncache.Cache
+}
+
+func NewImageDescriber(chat model.BaseChatModel, modelID string, cache *visioncache.Cache) *ImageDescriber {
+	return &ImageDescriber{model: chat, modelID: modelID, cache: cache}
+}
+
+func (d *ImageDescriber) Describe(ctx context.Context, part schema.ChatMessagePart) (string, error) {
+	inlined, err := inlineImage(part)
+	if err != nil {
+		return "", err
+	}
+	msg := schema.UserMessage("")
+	msg.MultiContent = []schema.ChatMessagePart{
+		{Type: schema.ChatMessagePartTypeText, Text: "请用不超过100字客观描述这张图片的关键内容，包括可见文字，便于后续对话在看不到原图时引用。"},
+		inlined,
+	}
+	resp, err := d.cache.Generate(ctx, d.model, d.modelID, []*schema.Message{msg})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Content), nil
+}
+
+// 只保留最近 keep 张原图，更早的图片替换为文字描述以节省 token
+func (m *Memory) CompactImages(ctx context.Context, session string, keep int, d *ImageDescriber) error {
+	type imageLoc struct {
+		msg  *schema.Message
+		idx  int
+		part schema.ChatMessagePart
+	}
+
+	m.mu.Lock()
+	var images []imageLoc
+	for _, msg := range m.sessions[session] {
+		for j, part := range msg.MultiContent {
+			if _, ok := imageRefPath(part); ok {
+				images = append(images, imageLoc{msg: msg, idx: j, part: part})
+			}
+		}
+	}
+	m.mu.Unlock()
+	if len(images) <= keep {
+		return nil
+	}
+
+	// 描述需要调用模型，放在锁外进行
+	stale := images[:len(images)-keep]
+	parts := make([]schema.ChatMessagePart, len(stale))
+	for i, img := range stale {
+		path, _ := imageRefPath(img.part)
+		desc, err := d.Describe(ctx, img.part)
+		var pathErr *fs.PathError
+		switch {
+		case errors.As(err, &pathErr):
+			// 原图已经读不到，描述不出来也没有必要再保留引用
+			log.Printf("⚠️ %v，改用文字占位", err)
+			parts[i] = missingImagePart(path)
+		case err != nil:
+			return err
+		default:
+			parts[i] = schema.ChatMessagePart{
+				Type: schema.ChatMessagePartTypeText,
+				Text: fmt.Sprintf("[图片 %s 的描述] %s", filepath.Base(path), desc),
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, img := range stale {
+		img.msg.MultiContent[img.idx] = parts[i]
+	}
+	return nil
+}
@@ -3,16 +3,23 @@ package main
 import (
 	"bufio"
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"slices"
 	"strings"
 	"sync"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
+	"agent-demo/llm"
+	"agent-demo/visioncache"
+
 	"github.com/cloudwego/eino/schema"
 )
 
+// 每个会话保留的历史消息条数
+const maxHistory = 10
+
 // 轻量内存实现
 type Memory struct {
 	mu       sync.Mutex
@@ -48,26 +55,32 @@ func (m *Memory) Trim(session string, max int) {
 func main() {
 	ctx := context.Background()
 
-	apiKey := os.Getenv("ARK_API_KEY")
-	baseURL := os.Getenv("ARK_BASE_URL")
-	modelID := os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请先设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL 环境变量")
-	}
+	noCache := flag.Bool("no-cache", false, "跳过图片描述缓存，强制调用模型")
+	cacheTTL := flag.Duration("cache-ttl", visioncache.DefaultTTL, "图片描述缓存有效期，<=0 表示永不过期")
+	flag.Parse()
 
-	chatModel, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL,
-		APIKey:  apiKey,
-		Model:   modelID,
-	})
+	cfg, err := llm.ConfigFromEnv()
 	if err != nil {
-		log.Fatalf("初始化 Ark ChatModel 失败: %v", err)
+		log.Fatal(err)
+	}
+	chatModel, err := llm.NewChatModel(ctx, cfg)
+	if err != nil {
+		log.Fatalf("初始化 ChatModel 失败: %v", err)
 	}
 
 	mem := NewMemory()
+	cache, err := visioncache.New("", *cacheTTL)
+	if err != nil {
+		log.Fatalf("初始化视觉缓存失败: %v", err)
+	}
+	if *noCache {
+		cache.Disable()
+	}
+	describer := NewImageDescriber(chatModel, cfg.Model, cache)
+	pendingImages := make(map[string]schema.ChatMessagePart)
 
 	reader := bufio.NewReader(os.Stdin)
-	fmt.Println("多轮对话 Demo 已启动。输入 `/a` 切换到会话A，`/b` 切换到会话B，`/exit` 退出。")
+	fmt.Println("多轮对话 Demo 已启动。输入 `/a` 切换到会话A，`/b` 切换到会话B，`/image <路径>` 为下一条消息附加图片，`/exit` 退出。")
 
 	session := "A"
 
@@ -79,8 +92,24 @@ func main() {
 			continue
 		}
 		if text == "/exit" {
+			fmt.Println("🗄️ 图片描述缓存：", cache.Stats())
 			break
 		}
+		if strings.HasPrefix(text, "/image") {
+			path := strings.TrimSpace(strings.TrimPrefix(text, "/image"))
+			if path == "" {
+				fmt.Println("用法：/image <图片路径>")
+				continue
+			}
+			part, err := newImagePart(path)
+			if err != nil {
+				log.Println("附加图片失败:", err)
+				continue
+			}
+			pendingImages[session] = part
+			fmt.Println("📎 已附加图片，将随下一条消息发送")
+			continue
+		}
 		if strings.HasPrefix(text, "/a") {
 			session = "A"
 			fmt.Println("👉 已切换到会话 A")
@@ -92,12 +121,27 @@ func main() {
 			continue
 		}
 
-		// 将用户输入加入记忆
-		mem.Add(session, schema.UserMessage(text))
-		mem.Trim(session, 10) // 限制最近10轮
+		// 构造本轮用户消息，有待发送的图片时一并附上
+		userMsg := schema.UserMessage(text)
+		keep := keepRecentImages
+		part, hasImage := pendingImages[session]
+		if hasImage {
+			userMsg = schema.UserMessage("")
+			userMsg.MultiContent = []schema.ChatMessagePart{
+				{Type: schema.ChatMessagePartTypeText, Text: text},
+				part,
+			}
+			keep--
+		}
+		// 本轮消息还未写入记忆，历史里只需保留剩余名额的原图
+		if err := mem.CompactImages(ctx, session, keep, describer); err != nil {
+			log.Println("压缩历史图片失败:", err)
+		}
 
-		// 获取上下文
-		msgs := mem.Get(session)
+		// 获取上下文（限制最近10条），图片引用在发送前才展开
+		history := mem.Get(session)
+		history = history[max(len(history)-(maxHistory-1), 0):]
+		msgs := expandImageRefs(append(slices.Clone(history), userMsg))
 
 		// 调用模型
 		resp, err := chatModel.Generate(ctx, msgs)
@@ -108,7 +152,10 @@ func main() {
 
 		fmt.Printf("[%s] AI：%s\n", session, resp.Content)
 
-		// 加入AI回复
+		// 发送成功后才把本轮问答写入记忆，失败时图片仍保留到下一条消息
+		delete(pendingImages, session)
+		mem.Add(session, userMsg)
 		mem.Add(session, schema.AssistantMessage(resp.Content, nil))
+		mem.Trim(session, maxHistory)
 	}
 }
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"agent-demo/router"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 标注样本：每行一个 JSON，如 {"query": "帮我看看这段 SQL 为什么慢", "profile": "logic"}
+type labeledQuery struct {
+	Query   string `json:"query"`
+	Profile string `json:"profile"`
+}
+
+// 用当前路由表逐条路由标注样本，输出错例、总体与各 profile 的准确率
+func evaluateRouting(ctx context.Context, r *router.Router, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	type counter struct{ total, correct int }
+	perProfile := make(map[string]*counter)
+	confusion := make(map[string]map[string]int)
+	total, correct := 0, 0
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var sample labeledQuery
+		if err := json.Unmarshal([]byte(text), &sample); err != nil {
+			return fmt.Errorf("%s 第 %d 行解析失败: %w", path, line, err)
+		}
+
+		decision := r.Route(ctx, []*schema.Message{schema.UserMessage(sample.Query)})
+		c := perProfile[sample.Profile]
+		if c == nil {
+			c = &counter{}
+			perProfile[sample.Profile] = c
+		}
+		if confusion[sample.Profile] == nil {
+			confusion[sample.Profile] = make(map[string]int)
+		}
+		confusion[sample.Profile][decision.Profile]++
+		total++
+		c.total++
+		if decision.Profile == sample.Profile {
+			correct++
+			c.correct++
+			continue
+		}
+		fmt.Printf("❌ %s\n   期望 %s，实际 %s（%s）\n", sample.Query, sample.Profile, decision.Profile, decision.Reason)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if total == 0 {
+		return fmt.Errorf("%s 中没有样本", path)
+	}
+
+	fmt.Printf("\n📊 策略 %s 路由准确率：%d/%d = %.1f%%\n", r.Strategy(), correct, total, float64(correct)*100/float64(total))
+	names := make([]string, 0, len(perProfile))
+	for name := range perProfile {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := perProfile[name]
+		var dist []string
+		for got, n := range confusion[name] {
+			dist = append(dist, fmt.Sprintf("%s=%d", got, n))
+		}
+		sort.Strings(dist)
+		fmt.Printf("  %-10s %d/%d = %.1f%%  [%s]\n", name, c.correct, c.total, float64(c.correct)*100/float64(c.total), strings.Join(dist, " "))
+	}
+	return nil
+}
@@ -1,93 +1,162 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
+
+	"agent-demo/router"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
 	"github.com/cloudwego/eino/schema"
 )
 
 func main() {
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	configPath := flag.String("config", "model_router/routes.yaml", "路由规则文件（YAML 或 JSON），修改后自动热加载")
+	query := flag.String("q", "", "单次提问；为空时进入交互模式")
+	evalPath := flag.String("eval", "", "标注样本文件（JSONL），用当前路由策略评测准确率后退出")
+	subject := flag.String("user", os.Getenv("USER"), "用户或会话 ID，配置了 A/B 实验时据此稳定分组")
+	serveAddr := flag.String("serve", "", "以 OpenAI 兼容的 HTTP 服务运行的监听地址，例如 :8080")
+	serveKey := flag.String("api-key", os.Getenv("ROUTER_API_KEY"), "HTTP 服务校验的 API key，为空时不校验")
+	flag.Parse()
 
-	apiKey := os.Getenv("ARK_API_KEY")
-	baseURL := os.Getenv("ARK_BASE_URL")
-	if apiKey == "" || baseURL == "" {
-		log.Fatal("请先设置 ARK_API_KEY 与 ARK_BASE_URL 环境变量")
+	r, err := router.NewFromEnv(ctx, *configPath)
+	if err != nil {
+		log.Fatal(err)
 	}
+	fmt.Print(r.Summary())
+
+	if *evalPath != "" {
+		if err := evaluateRouting(ctx, r, *evalPath); err != nil {
+			log.Fatalf("路由评测失败: %v", err)
+		}
+		return
+	}
+
+	go r.Watch(ctx, 2*time.Second)
+	go r.HealthCheck(ctx)
 
-	modelIDs := map[string]string{
-		"default": firstNonEmpty(os.Getenv("ARK_MODEL_DEFAULT"), os.Getenv("ARK_MODEL")),
-		"fast":    os.Getenv("ARK_MODEL_FAST"),
-		"logic":   os.Getenv("ARK_MODEL_LOGIC"),
+	if *serveAddr != "" {
+		if err := serve(ctx, r, *serveAddr, *serveKey); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *subject != "" {
+		ctx = router.WithSubject(ctx, *subject)
 	}
-	if modelIDs["default"] == "" {
-		log.Fatal("请至少配置 ARK_MODEL 或 ARK_MODEL_DEFAULT")
+
+	if *query != "" {
+		answer(ctx, r, *query)
+		return
 	}
 
-	models := make(map[string]*ark.ChatModel)
-	for name, modelID := range modelIDs {
-		if modelID == "" {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Println("模型路由 Demo 已启动，输入问题开始对话，`/good` `/bad` 评价上一条回答，`/stats` 查看统计，`/status` 查看模型健康与权重，`/exit` 退出。")
+	for {
+		fmt.Print("你：")
+		text, err := reader.ReadString('\n')
+		text = strings.TrimSpace(text)
+		if text == "/exit" || (err != nil && text == "") {
+			break
+		}
+		if text == "" {
 			continue
 		}
-		m, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-			BaseURL: baseURL,
-			APIKey:  apiKey,
-			Model:   modelID,
-		})
-		if err != nil {
-			log.Fatalf("初始化模型 %s 失败: %v", name, err)
+		if text == "/stats" {
+			fmt.Println("📈", r.HedgeStats())
+			if r.Strategy() == "cascade" {
+				fmt.Println("💰", r.CascadeStats())
+			}
+			fmt.Println("👥", r.ShadowStats())
+			limits := r.RateLimitStats()
+			ids := make([]string, 0, len(limits))
+			for id := range limits {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			for _, id := range ids {
+				fmt.Printf("🚦 %s：%s\n", id, limits[id])
+			}
+			for _, v := range r.ExperimentStats() {
+				fmt.Println("🧪", v)
+			}
+			continue
 		}
-		models[name] = m
-		fmt.Printf("✅ 模型实例化成功：%s -> %s\n", name, modelID)
-	}
-
-	selectModel := func(query string) string {
-		lower := strings.ToLower(query)
-		switch {
-		case strings.Contains(lower, "代码"), strings.Contains(lower, "算法"):
-			if models["logic"] != nil {
-				return "logic"
+		if text == "/status" {
+			for _, h := range r.Health() {
+				fmt.Println("🩺", h)
 			}
-		case len([]rune(query)) > 200:
-			if models["fast"] != nil {
-				return "fast"
+			continue
+		}
+		if text == "/good" || text == "/bad" {
+			variant, err := r.Feedback(*subject, text == "/good")
+			if err != nil {
+				fmt.Println("⚠️", err)
+				continue
 			}
+			fmt.Printf("📝 已记录对 variant %s 的反馈\n", variant)
+			continue
 		}
-		return "default"
+		answer(ctx, r, text)
 	}
+}
 
-	userInput := "请帮我写一个快速排序的 Go 实现"
-	modelName := selectModel(userInput)
-	fmt.Printf("🧭 已路由到模型：%s\n", modelName)
+// 运行 OpenAI 兼容服务，收到 Ctrl+C 后等待进行中的请求结束再退出
+func serve(ctx context.Context, r *router.Router, addr, apiKey string) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	selectedModel := models[modelName]
-	if selectedModel == nil {
-		log.Fatalf("模型 %s 未初始化", modelName)
+	srv := &http.Server{Addr: addr, Handler: newProxyServer(r, apiKey), ReadHeaderTimeout: 10 * time.Second}
+	// Shutdown 一开始 ListenAndServe 就会返回，要等 Shutdown 把进行中的请求处理完
+	done := make(chan struct{})
+	var shutdownErr error
+	go func() {
+		defer close(done)
+		<-ctx.Done()
+		fmt.Println("👋 正在关闭服务，等待进行中的请求结束……")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			shutdownErr = fmt.Errorf("关闭 HTTP 服务失败: %w", err)
+		}
+	}()
+	fmt.Printf("🌐 OpenAI 兼容服务已启动：http://%s/v1（model 可用 %s）\n", addr, strings.Join(append([]string{modelAuto}, r.Profiles()...), "、"))
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
 	}
+	<-done
+	return shutdownErr
+}
 
+func answer(ctx context.Context, r *router.Router, userInput string) {
 	messages := []*schema.Message{
 		schema.SystemMessage("你是一个智能助手，请详细回答用户的问题。"),
 		schema.UserMessage(userInput),
 	}
 
-	resp, err := selectedModel.Generate(ctx, messages)
+	resp, decision, record, err := r.GenerateWithRecord(ctx, messages)
+	fmt.Printf("🧭 已路由到模型：%s（%s）\n", decision.Profile, decision.Reason)
+	fmt.Println("🔗 调用链路：", record)
 	if err != nil {
-		log.Fatalf("模型生成失败: %v", err)
+		log.Printf("模型生成失败: %v", err)
+		return
 	}
 
-	fmt.Println("🤖 模型回答：", resp.Content)
-}
-
-func firstNonEmpty(values ...string) string {
-	for _, v := range values {
-		if strings.TrimSpace(v) != "" {
-			return v
-		}
+	if variant, ok := resp.Extra[router.ExtraKeyVariant]; ok {
+		fmt.Println("🧪 A/B 分组：", variant)
 	}
-	return ""
+	fmt.Printf("🤖 模型回答（%s）：%s\n", record.Answered, resp.Content)
 }
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"agent-demo/agent"
+	"agent-demo/ratelimit"
+	"agent-demo/router"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+)
+
+// OpenAI 兼容的代理服务：/v1/chat/completions 与 /v1/models。
+// 请求中的 model 为 auto（或留空）时按路由策略选择，为 profile 名时直接使用该 profile
+
+const (
+	modelAuto = "auto"
+	// 带 base64 图片的请求体可能较大
+	maxRequestBytes = 32 << 20
+)
+
+type proxyServer struct {
+	router *router.Router
+	apiKey string // 为空时不校验 Authorization
+}
+
+func newProxyServer(r *router.Router, apiKey string) http.Handler {
+	s := &proxyServer{router: r, apiKey: apiKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/models", s.auth(s.listModels))
+	mux.HandleFunc("POST /v1/chat/completions", s.auth(s.chatCompletions))
+	return mux
+}
+
+func (s *proxyServer) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		// 逐字节比较会因耗时差异泄露 key 的前缀，改用常数时间比较
+		if s.apiKey != "" && subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte("Bearer "+s.apiKey)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "API key 无效")
+			return
+		}
+		next(w, req)
+	}
+}
+
+// ---- 请求与响应的 JSON 结构，字段与 OpenAI Chat Completions API 一致 ----
+
+type chatRequest struct {
+	Model               string          `json:"model"`
+	Messages            []chatMessage   `json:"messages"`
+	Tools               []chatTool      `json:"tools"`
+	ToolChoice          json.RawMessage `json:"tool_choice"`
+	Stream              bool            `json:"stream"`
+	StreamOptions       *streamOptions  `json:"stream_options"`
+	Temperature         *float32        `json:"temperature"`
+	TopP                *float32        `json:"top_p"`
+	MaxTokens           *int            `json:"max_tokens"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens"`
+	Stop                json.RawMessage `json:"stop"`
+	User                string          `json:"user"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatMessage struct {
+	Role             string          `json:"role,omitempty"`
+	Content          json.RawMessage `json:"content,omitempty"` // 字符串或内容片段数组
+	ReasoningContent string          `json:"reasoning_content,omitempty"`
+	Name             string          `json:"name,omitempty"`
+	ToolCalls        []chatToolCall  `json:"tool_calls,omitempty"`
+	ToolCallID       string          `json:"tool_call_id,omitempty"`
+}
+
+type contentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL *struct {
+		URL    string `json:"url"`
+		Detail string `json:"detail,omitempty"`
+	} `json:"image_url,omitempty"`
+}
+
+type chatToolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type chatTool struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string             `json:"name"`
+		Description string             `json:"description"`
+		Parameters  *jsonschema.Schema `json:"parameters"`
+	} `json:"function"`
+}
+
+type chatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []chatChoice `json:"choices"`
+	Usage   *chatUsage   `json:"usage,omitempty"`
+}
+
+type chatChoice struct {
+	Index        int          `json:"index"`
+	Message      *chatMessage `json:"message,omitempty"`
+	Delta        *chatMessage `json:"delta,omitempty"`
+	FinishReason *string      `json:"finish_reason"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type modelEntry struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ---- handlers ----
+
+// 列出可用的模型别名：auto 加上所有已配置模型的 profile
+func (s *proxyServer) listModels(w http.ResponseWriter, _ *http.Request) {
+	now := time.Now().Unix()
+	data := []modelEntry{{ID: modelAuto, Object: "model", Created: now, OwnedBy: "router"}}
+	for _, name := range s.router.Profiles() {
+		data = append(data, modelEntry{ID: name, Object: "model", Created: now, OwnedBy: "router"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+func (s *proxyServer) chatCompletions(w http.ResponseWriter, req *http.Request) {
+	var body chatRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRequestBytes)).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "请求体不是合法的 JSON: "+err.Error())
+		return
+	}
+	if len(body.Messages) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", "messages 不能为空")
+		return
+	}
+
+	ctx := req.Context()
+	alias := body.Model
+	if alias == "" {
+		alias = modelAuto
+	}
+	if alias != modelAuto {
+		if !s.hasProfile(alias) {
+			writeError(w, http.StatusNotFound, "invalid_request_error", "model_not_found",
+				fmt.Sprintf("模型 %q 不存在，可用的有 %s", alias, strings.Join(append([]string{modelAuto}, s.router.Profiles()...), "、")))
+			return
+		}
+		ctx = router.WithProfile(ctx, alias)
+	}
+	if body.User != "" {
+		ctx = router.WithSubject(ctx, body.User)
+	}
+
+	msgs, err := toSchemaMessages(body.Messages)
+	if err == nil {
+		// 工具结果必须紧跟对应的工具调用，顺序不对时上游同样会拒绝，这里提前返回 400
+		err = agent.ValidateMessages(msgs)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+	chat, opts, err := s.prepare(&body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "", err.Error())
+		return
+	}
+
+	id := "chatcmpl-" + randomID()
+	if body.Stream {
+		s.stream(ctx, w, chat, msgs, opts, id, alias, body.StreamOptions != nil && body.StreamOptions.IncludeUsage)
+		return
+	}
+
+	resp, decision, record, err := chat.GenerateWithRecord(ctx, msgs, opts...)
+	log.Printf("🧭 [%s] %s -> %s（%s），链路：%s", id, alias, decision.Profile, decision.Reason, record)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+	setRouterHeaders(w, record)
+	finish := finishReason(resp)
+	writeJSON(w, http.StatusOK, chatResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   record.Answered,
+		Choices: []chatChoice{{Message: fromSchemaMessage(resp), FinishReason: &finish}},
+		Usage:   usageOf(resp),
+	})
+}
+
+// 在响应头里标明实际应答的 profile 与 A/B 分组，流式与非流式一致
+func setRouterHeaders(w http.ResponseWriter, record *router.CallRecord) {
+	w.Header().Set("X-Router-Profile", record.Answered)
+	if record.Variant != "" {
+		w.Header().Set("X-Router-Variant", record.Variant)
+	}
+}
+
+// 以 SSE 逐块返回 chat.completion.chunk，最后发送 data: [DONE]。
+// 流开始后出错时只能在流里发送一个 error 事件再结束
+func (s *proxyServer) stream(ctx context.Context, w http.ResponseWriter, chat *router.Router, msgs []*schema.Message, opts []model.Option, id, alias string, includeUsage bool) {
+	sr, _, record, err := chat.StreamWithRecord(ctx, msgs, opts...)
+	if err != nil {
+		log.Printf("🧭 [%s] %s 流式请求失败: %v", id, alias, err)
+		writeModelError(w, err)
+		return
+	}
+	defer sr.Close()
+
+	// 与非流式响应一致，model 填实际应答的 profile（回退或对冲时与请求的不同）
+	answered := record.Answered
+	setRouterHeaders(w, record)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	send := func(v any) {
+		raw, _ := json.Marshal(v)
+		fmt.Fprintf(w, "data: %s\n\n", raw)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	created := time.Now().Unix()
+	chunkOf := func(delta *chatMessage, finish *string) chatResponse {
+		return chatResponse{ID: id, Object: "chat.completion.chunk", Created: created, Model: answered,
+			Choices: []chatChoice{{Delta: delta, FinishReason: finish}}}
+	}
+	send(chunkOf(&chatMessage{Role: "assistant", Content: json.RawMessage(`""`)}, nil))
+
+	var (
+		usage    *chatUsage
+		finish   string
+		hasTools bool
+	)
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("🧭 [%s] 流式响应中断: %v", id, err)
+			send(map[string]any{"error": apiError{Message: err.Error(), Type: "server_error"}})
+			return
+		}
+		if u := usageOf(chunk); u != nil {
+			usage = u
+		}
+		if chunk.ResponseMeta != nil && chunk.ResponseMeta.FinishReason != "" {
+			finish = chunk.ResponseMeta.FinishReason
+		}
+		delta := &chatMessage{ReasoningContent: chunk.ReasoningContent}
+		if chunk.Content != "" {
+			delta.Content = jsonString(chunk.Content)
+		}
+		for i, call := range chunk.ToolCalls {
+			hasTools = true
+			delta.ToolCalls = append(delta.ToolCalls, toolCallOf(call, i))
+		}
+		if delta.Content == nil && delta.ReasoningContent == "" && len(delta.ToolCalls) == 0 {
+			continue
+		}
+		send(chunkOf(delta, nil))
+	}
+
+	if finish == "" {
+		finish = "stop"
+		if hasTools {
+			finish = "tool_calls"
+		}
+	}
+	send(chunkOf(&chatMessage{}, &finish))
+	if includeUsage && usage != nil {
+		send(chatResponse{ID: id, Object: "chat.completion.chunk", Created: created, Model: answered, Choices: []chatChoice{}, Usage: usage})
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+func (s *proxyServer) hasProfile(name string) bool {
+	for _, p := range s.router.Profiles() {
+		if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 把工具与生成参数转换为 Router 的调用方式：工具通过 WithTools 绑定，其余参数作为 model.Option 传入
+func (s *proxyServer) prepare(body *chatRequest) (*router.Router, []model.Option, error) {
+	var opts []model.Option
+	if body.Temperature != nil {
+		opts = append(opts, model.WithTemperature(*body.Temperature))
+	}
+	if body.TopP != nil {
+		opts = append(opts, model.WithTopP(*body.TopP))
+	}
+	if body.MaxCompletionTokens != nil {
+		opts = append(opts, model.WithMaxTokens(*body.MaxCompletionTokens))
+	} else if body.MaxTokens != nil {
+		opts = append(opts, model.WithMaxTokens(*body.MaxTokens))
+	}
+	if stop, err := stopSequences(body.Stop); err != nil {
+		return nil, nil, err
+	} else if len(stop) > 0 {
+		opts = append(opts, model.WithStop(stop))
+	}
+
+	tools := make([]*schema.ToolInfo, 0, len(body.Tools))
+	for _, t := range body.Tools {
+		if t.Type != "" && t.Type != "function" {
+			return nil, nil, fmt.Errorf("不支持的工具类型 %q", t.Type)
+		}
+		if t.Function.Name == "" {
+			return nil, nil, errors.New("tools[].function.name 不能为空")
+		}
+		info := &schema.ToolInfo{Name: t.Function.Name, Desc: t.Function.Description}
+		if t.Function.Parameters != nil {
+			info.ParamsOneOf = schema.NewParamsOneOfByJSONSchema(t.Function.Parameters)
+		}
+		tools = append(tools, info)
+	}
+
+	// tool_choice 可以是 "none" / "auto" / "required"，或 {"type":"function","function":{"name":...}}
+	if len(body.ToolChoice) > 0 && string(body.ToolChoice) != "null" {
+		var mode string
+		if err := json.Unmarshal(body.ToolChoice, &mode); err == nil {
+			switch mode {
+			case "none":
+				tools = nil
+			case "auto":
+			case "required":
+				opts = append(opts, model.WithToolChoice(schema.ToolChoiceForced))
+			default:
+				return nil, nil, fmt.Errorf("不支持的 tool_choice %q", mode)
+			}
+		} else {
+			var named struct {
+				Function struct {
+					Name string `json:"name"`
+				} `json:"function"`
+			}
+			if err := json.Unmarshal(body.ToolChoice, &named); err != nil || named.Function.Name == "" {
+				return nil, nil, errors.New("tool_choice 格式不正确")
+			}
+			// 指定工具时只绑定这一个工具并要求必须调用
+			var picked []*schema.ToolInfo
+			for _, t := range tools {
+				if t.Name == named.Function.Name {
+					picked = append(picked, t)
+				}
+			}
+			if len(picked) == 0 {
+				return nil, nil, fmt.Errorf("tool_choice 指定的工具 %q 不在 tools 中", named.Function.Name)
+			}
+			tools = picked
+			opts = append(opts, model.WithToolChoice(schema.ToolChoiceForced))
+		}
+	}
+
+	if len(tools) == 0 {
+		return s.router, opts, nil
+	}
+	bound, err := s.router.WithTools(tools)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bound.(*router.Router), opts, nil
+}
+
+// ---- 消息转换 ----
+
+func toSchemaMessages(in []chatMessage) ([]*schema.Message, error) {
+	out := make([]*schema.Message, 0, len(in))
+	for i, m := range in {
+		msg := &schema.Message{Name: m.Name, ReasoningContent: m.ReasoningContent, ToolCallID: m.ToolCallID}
+		switch m.Role {
+		case "system", "developer":
+			msg.Role = schema.System
+		case "user":
+			msg.Role = schema.User
+		case "assistant":
+			msg.Role = schema.Assistant
+		case "tool":
+			msg.Role = schema.Tool
+			if m.ToolCallID == "" {
+				return nil, fmt.Errorf("messages[%d]：tool 消息缺少 tool_call_id", i)
+			}
+		default:
+			return nil, fmt.Errorf("messages[%d]：不支持的角色 %q", i, m.Role)
+		}
+
+		if err := decodeContent(m.Content, msg); err != nil {
+			return nil, fmt.Errorf("messages[%d]：%w", i, err)
+		}
+		for j, call := range m.ToolCalls {
+			index := j
+			msg.ToolCalls = append(msg.ToolCalls, schema.ToolCall{
+				Index:    &index,
+				ID:       call.ID,
+				Type:     "function",
+				Function: schema.FunctionCall{Name: call.Function.Name, Arguments: call.Function.Arguments},
+			})
+		}
+		out = append(out, msg)
+	}
+	return out, nil
+}
+
+// content 为字符串时直接使用；为片段数组时，纯文本拼接成 Content，含图片时转为 MultiContent
+func decodeContent(raw json.RawMessage, msg *schema.Message) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(raw, &msg.Content); err == nil {
+		return nil
+	}
+	var parts []contentPart
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return errors.New("content 必须是字符串或内容片段数组")
+	}
+	var (
+		texts    []string
+		hasImage bool
+		multi    []schema.ChatMessagePart
+	)
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			texts = append(texts, p.Text)
+			multi = append(multi, schema.ChatMessagePart{Type: schema.ChatMessagePartTypeText, Text: p.Text})
+		case "image_url":
+			if p.ImageURL == nil || p.ImageURL.URL == "" {
+				return errors.New("image_url 片段缺少 url")
+			}
+			hasImage = true
+			multi = append(multi, schema.ChatMessagePart{
+				Type:     schema.ChatMessagePartTypeImageURL,
+				ImageURL: &schema.ChatMessageImageURL{URL: p.ImageURL.URL, Detail: schema.ImageURLDetail(p.ImageURL.Detail)},
+			})
+		default:
+			return fmt.Errorf("不支持的内容片段类型 %q", p.Type)
+		}
+	}
+	if hasImage {
+		msg.MultiContent = multi
+	} else {
+		msg.Content = strings.Join(texts, "\n")
+	}
+	return nil
+}
+
+func fromSchemaMessage(msg *schema.Message) *chatMessage {
+	out := &chatMessage{Role: "assistant", ReasoningContent: msg.ReasoningContent}
+	if msg.Content != "" || len(msg.ToolCalls) == 0 {
+		out.Content = jsonString(msg.Content)
+	} else {
+		out.Content = json.RawMessage("null")
+	}
+	for i, call := range msg.ToolCalls {
+		out.ToolCalls = append(out.ToolCalls, toolCallOf(call, i))
+	}
+	return out
+}
+
+func toolCallOf(call schema.ToolCall, fallbackIndex int) chatToolCall {
+	index := fallbackIndex
+	if call.Index != nil {
+		index = *call.Index
+	}
+	out := chatToolCall{Index: &index, ID: call.ID, Type: call.Type}
+	if out.Type == "" && out.ID != "" {
+		out.Type = "function"
+	}
+	out.Function.Name = call.Function.Name
+	out.Function.Arguments = call.Function.Arguments
+	return out
+}
+
+func finishReason(msg *schema.Message) string {
+	if msg.ResponseMeta != nil && msg.ResponseMeta.FinishReason != "" {
+		return msg.ResponseMeta.FinishReason
+	}
+	if len(msg.ToolCalls) > 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+func usageOf(msg *schema.Message) *chatUsage {
+	if msg.ResponseMeta == nil || msg.ResponseMeta.Usage == nil || msg.ResponseMeta.Usage.TotalTokens == 0 {
+		return nil
+	}
+	u := msg.ResponseMeta.Usage
+	return &chatUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// stop 可以是单个字符串或字符串数组
+func stopSequences(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, errors.New("stop 必须是字符串或字符串数组")
+	}
+	return many, nil
+}
+
+// ---- 错误与工具函数 ----
+
+type apiError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+// 按错误类型映射 HTTP 状态码：能力不足为 400，限流为 429，其余视为上游模型故障
+func writeModelError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, router.ErrNoCapableModel):
+		writeError(w, http.StatusBadRequest, "invalid_request_error", "no_capable_model", err.Error())
+	case errors.Is(err, ratelimit.ErrWaitTooLong) || ratelimit.IsRateLimited(err):
+		writeError(w, http.StatusTooManyRequests, "rate_limit_error", "rate_limit_exceeded", err.Error())
+	case errors.Is(err, context.Canceled):
+		// 客户端已断开，无需响应
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, http.StatusGatewayTimeout, "server_error", "timeout", err.Error())
+	default:
+		writeError(w, http.StatusBadGateway, "server_error", "upstream_error", err.Error())
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, typ, code, message string) {
+	writeJSON(w, status, map[string]any{"error": apiError{Message: message, Type: typ, Code: code}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("写入响应失败: %v", err)
+	}
+}
+
+func jsonString(s string) json.RawMessage {
+	raw, _ := json.Marshal(s)
+	return raw
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
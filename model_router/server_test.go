@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"agent-demo/llm"
+	"agent-demo/router"
+)
+
+// primary 总是超时，由回退链上的 backup 应答
+func newTestServer(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+	return newServerWithConfig(t, apiKey, `
+strategy: rules
+default_profile: primary
+profiles:
+  primary:
+    model: primary-model
+    timeout_ms: 20
+    fallbacks: [backup]
+  backup:
+    model: backup-model
+`, `
+rules:
+  - model: primary-model
+    delay_ms: 1000
+  - content: 你好
+`)
+}
+
+func newServerWithConfig(t *testing.T, apiKey, routesYAML, scriptYAML string) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	routes := filepath.Join(dir, "routes.yaml")
+	script := filepath.Join(dir, "mock.yaml")
+	if err := os.WriteFile(routes, []byte(routesYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte(scriptYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := router.New(context.Background(), routes, llm.Config{Provider: llm.ProviderMock, Model: "mock", MockScript: script})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(newProxyServer(r, apiKey))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func post(t *testing.T, srv *httptest.Server, auth, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestAuth(t *testing.T) {
+	srv := newTestServer(t, "secret")
+	body := `{"messages":[{"role":"user","content":"hi"}]}`
+	for _, auth := range []string{"", "Bearer wrong", "Bearer secre", "Bearer secret2", "secret"} {
+		if resp := post(t, srv, auth, body); resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q 的状态码 = %d，期望 401", auth, resp.StatusCode)
+		}
+	}
+	if resp := post(t, srv, "Bearer secret", body); resp.StatusCode != http.StatusOK {
+		t.Errorf("正确的 key 状态码 = %d", resp.StatusCode)
+	}
+}
+
+// 流式与非流式响应的 model 都应是实际应答的 profile
+func TestResponseModelIsAnsweredProfile(t *testing.T) {
+	srv := newTestServer(t, "")
+
+	resp := post(t, srv, "", `{"model":"auto","messages":[{"role":"user","content":"hi"}]}`)
+	var full chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&full); err != nil {
+		t.Fatal(err)
+	}
+	if full.Model != "backup" {
+		t.Errorf("非流式 model = %q，期望 backup", full.Model)
+	}
+
+	resp = post(t, srv, "", `{"model":"auto","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`)
+	if got := resp.Header.Get("X-Router-Profile"); got != "backup" {
+		t.Errorf("X-Router-Profile = %q，期望 backup", got)
+	}
+	chunks := 0
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok || data == "[DONE]" {
+			continue
+		}
+		var chunk chatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("无法解析 chunk %s: %v", data, err)
+		}
+		chunks++
+		if chunk.Model != "backup" {
+			t.Errorf("流式 chunk 的 model = %q，期望 backup", chunk.Model)
+		}
+	}
+	if chunks == 0 {
+		t.Fatal("没有收到任何 chunk")
+	}
+}
+
+// 参与 A/B 实验的请求，流式与非流式都在响应头里给出分组
+func TestVariantHeader(t *testing.T) {
+	srv := newServerWithConfig(t, "", `
+strategy: rules
+default_profile: a
+profiles:
+  a:
+    model: model-a
+  b:
+    model: model-b
+experiment:
+  name: exp
+  variants:
+    - {name: control, profile: a, weight: 1}
+    - {name: treatment, profile: b, weight: 1}
+`, `
+rules:
+  - content: 你好
+`)
+
+	for _, stream := range []bool{false, true} {
+		body := fmt.Sprintf(`{"model":"auto","user":"u-1","stream":%t,"messages":[{"role":"user","content":"hi"}]}`, stream)
+		resp := post(t, srv, "", body)
+		io.Copy(io.Discard, resp.Body)
+		variant := resp.Header.Get("X-Router-Variant")
+		if variant != "exp/control" && variant != "exp/treatment" {
+			t.Errorf("stream=%t 时 X-Router-Variant = %q", stream, variant)
+		}
+		want := map[string]string{"exp/control": "a", "exp/treatment": "b"}[variant]
+		if got := resp.Header.Get("X-Router-Profile"); got != want {
+			t.Errorf("stream=%t 时 X-Router-Profile = %q，期望 %q", stream, got, want)
+		}
+	}
+
+	resp := post(t, srv, "", `{"model":"auto","messages":[{"role":"user","content":"hi"}]}`)
+	if got := resp.Header.Get("X-Router-Variant"); got != "" {
+		t.Errorf("没有 user 的请求不参与实验，X-Router-Variant = %q", got)
+	}
+}
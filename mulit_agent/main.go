@@ -8,7 +8,10 @@ import (
 	"strings"
 	"time"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
+	"agent-demo/llm"
+	"agent-demo/router"
+
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 )
 
@@ -16,7 +19,7 @@ import (
 type Agent struct {
 	name   string
 	system string
-	model  *ark.ChatModel
+	model  model.BaseChatModel
 }
 
 func (a *Agent) Act(ctx context.Context, history []*schema.Message, userHint string) (string, error) {
@@ -36,17 +39,7 @@ func (a *Agent) Act(ctx context.Context, history []*schema.Message, userHint str
 func main() {
 	ctx := context.Background()
 
-	apiKey, baseURL, modelID := os.Getenv("ARK_API_KEY"), os.Getenv("ARK_BASE_URL"), os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL")
-	}
-
-	chat, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL,
-		APIKey:  apiKey,
-		Model:   modelID,
-		// 可选：增加超时/重试等
-	})
+	chat, err := newChatModel(ctx)
 	if err != nil {
 		log.Fatal("init model:", err)
 	}
@@ -138,6 +131,22 @@ func main() {
 	}
 }
 
+// 设置 ARK_ROUTER_CONFIG 时用模型路由器替代单个模型，调用方无需任何改动
+func newChatModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	if routerConfig := os.Getenv("ARK_ROUTER_CONFIG"); routerConfig != "" {
+		return router.NewFromEnv(ctx, routerConfig)
+	}
+
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Provider == llm.ProviderMock && cfg.MockScript == "" {
+		cfg.MockScript = "mulit_agent/mock.yaml"
+	}
+	return llm.NewChatModel(ctx, cfg)
+}
+
 func parseTarget(s string) string {
 	// 期望格式：【To:Researcher】或【To:Writer】
 	if i := strings.Index(s, "【To:"); i >= 0 {
@@ -9,29 +9,20 @@ import (
 	"sort"
 	"strings"
 
+	"agent-demo/llm"
+	"agent-demo/router"
+
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/schema"
-
-	"github.com/cloudwego/eino-ext/components/model/ark"
 )
 
 func main() {
 	ctx := context.Background()
 
-	apiKey := os.Getenv("ARK_API_KEY")
-	baseURL := os.Getenv("ARK_BASE_URL")
-	modelID := os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL 环境变量")
-	}
-
-	chat, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL,
-		APIKey:  apiKey,
-		Model:   modelID,
-	})
+	chat, err := newChatModel(ctx)
 	if err != nil {
-		log.Fatalf("初始化 Ark ChatModel 失败: %v", err)
+		log.Fatalf("初始化 ChatModel 失败: %v", err)
 	}
 
 	docs := []string{
@@ -136,6 +127,19 @@ func main() {
 	}
 }
 
+// 设置 ARK_ROUTER_CONFIG 时用模型路由器替代单个模型，调用方无需任何改动
+func newChatModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	if routerConfig := os.Getenv("ARK_ROUTER_CONFIG"); routerConfig != "" {
+		return router.NewFromEnv(ctx, routerConfig)
+	}
+
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return llm.NewChatModel(ctx, cfg)
+}
+
 type routePayload struct {
 	Query string
 	Route string
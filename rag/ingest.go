@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent-demo/llm"
+	"agent-demo/textvec"
+	"agent-demo/visioncache"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+var imageExts = map[string]bool{
+	".png":  true,
+	".jpg":  true,
+	".jpeg": true,
+	".webp": true,
+	".gif":  true,
+}
+
+const captionPrompt = `请仔细观察这张图片，并严格按如下 JSON 输出（不要输出其它内容）：
+{"caption": "详细描述图片内容、结构与要点", "visible_text": "图片中所有可见文字，按阅读顺序", "keywords": ["5~10个检索关键词"]}`
+
+type imageCaption struct {
+	Caption     string   `json:"caption"`
+	VisibleText string   `json:"visible_text"`
+	Keywords    []string `json:"keywords"`
+}
+
+// 遍历目录下的图片，调用视觉模型生成描述与可见文字，转换为可检索的知识条目
+func ingestImages(ctx context.Context, chat model.BaseChatModel, modelID string, cache *visioncache.Cache, root string) ([]knowledgeDoc, error) {
+	var docs []knowledgeDoc
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !imageExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		caption, err := captionImage(ctx, chat, modelID, cache, path)
+		if err != nil {
+			// 单张图片失败不影响其它图片入库
+			log.Printf("⚠️ 图片描述失败（%s）: %v", path, err)
+			return nil
+		}
+		text := formatCaption(caption)
+		docs = append(docs, knowledgeDoc{
+			Text:   text,
+			Source: path,
+			Vector: textvec.FromText(text),
+		})
+		fmt.Printf("🖼️ 已入库：%s\n", path)
+		return nil
+	})
+	return docs, err
+}
+
+func captionImage(ctx context.Context, chat model.BaseChatModel, modelID string, cache *visioncache.Cache, path string) (*imageCaption, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mimeType := http.DetectContentType(data)
+
+	msg := schema.UserMessage("")
+	msg.MultiContent = []schema.ChatMessagePart{
+		{Type: schema.ChatMessagePartTypeText, Text: captionPrompt},
+		{
+			Type: schema.ChatMessagePartTypeImageURL,
+			ImageURL: &schema.ChatMessageImageURL{
+				URL:      fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data)),
+				MIMEType: mimeType,
+				Detail:   schema.ImageURLDetailHigh,
+			},
+		},
+	}
+
+	resp, err := cache.Generate(ctx, chat, modelID, []*schema.Message{msg})
+	if err != nil {
+		return nil, err
+	}
+
+	var caption imageCaption
+	if err := json.Unmarshal([]byte(llm.TrimJSONFence(resp.Content)), &caption); err != nil {
+		return nil, fmt.Errorf("解析图片描述失败: %w", err)
+	}
+	return &caption, nil
+}
+
+// 关键词用空格分隔写入正文，方便按空白切词的 textvec.Tokenize 命中
+func formatCaption(c *imageCaption) string {
+	var builder strings.Builder
+	builder.WriteString("[图片] ")
+	builder.WriteString(c.Caption)
+	if t := strings.TrimSpace(c.VisibleText); t != "" {
+		builder.WriteString(" 图中文字：")
+		builder.WriteString(t)
+	}
+	if len(c.Keywords) > 0 {
+		builder.WriteString(" 关键词： ")
+		builder.WriteString(strings.Join(c.Keywords, " "))
+	}
+	return builder.String()
+}
@@ -2,36 +2,37 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
-	"math"
 	"os"
 	"sort"
 	"strings"
 
-	"github.com/cloudwego/eino/schema"
+	"agent-demo/llm"
+	"agent-demo/textvec"
+	"agent-demo/visioncache"
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/schema"
 )
 
 func main() {
 	ctx := context.Background()
 
-	// =============== 1. 初始化 Ark Chat 模型 ===============
-	apiKey := os.Getenv("ARK_API_KEY")
-	baseURL := os.Getenv("ARK_BASE_URL")
-	modelID := os.Getenv("ARK_MODEL")
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL 环境变量")
-	}
+	imageDir := flag.String("images", "", "需要入库的图片目录，为空则只使用内置知识")
+	query := flag.String("q", "Eino 的 Stream 是怎么实现的？", "提问内容")
+	noCache := flag.Bool("no-cache", false, "跳过图片描述缓存，强制调用视觉模型")
+	cacheTTL := flag.Duration("cache-ttl", visioncache.DefaultTTL, "图片描述缓存有效期，<=0 表示永不过期")
+	flag.Parse()
 
-	chat, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL,
-		APIKey:  apiKey,
-		Model:   modelID,
-	})
+	// =============== 1. 初始化 Chat 模型 ===============
+	cfg, err := llm.ConfigFromEnv()
 	if err != nil {
-		log.Fatalf("初始化 Ark ChatModel 失败: %v", err)
+		log.Fatal(err)
+	}
+	chat, err := llm.NewChatModel(ctx, cfg)
+	if err != nil {
+		log.Fatalf("初始化 ChatModel 失败: %v", err)
 	}
 
 	// =============== 2. 准备知识库并构建简单向量索引 ===============
@@ -42,17 +43,39 @@ func main() {
 	}
 
 	index := buildKnowledgeIndex(docs)
+
+	// 图片入库：视觉模型可单独配置，默认与对话模型相同
+	if *imageDir != "" {
+		visionCfg := cfg
+		visionCfg.Model = firstNonEmpty(os.Getenv("ARK_VISION_MODEL"), cfg.Model)
+		vision, err := llm.NewChatModel(ctx, visionCfg)
+		if err != nil {
+			log.Fatalf("初始化视觉模型失败: %v", err)
+		}
+		cache, err := visioncache.New("", *cacheTTL)
+		if err != nil {
+			log.Fatalf("初始化视觉缓存失败: %v", err)
+		}
+		if *noCache {
+			cache.Disable()
+		}
+		imageDocs, err := ingestImages(ctx, vision, visionCfg.Model, cache, *imageDir)
+		if err != nil {
+			log.Fatalf("图片入库失败: %v", err)
+		}
+		index = append(index, imageDocs...)
+		fmt.Printf("🖼️ 图片入库 %d 张，描述缓存：%s\n", len(imageDocs), cache.Stats())
+	}
 	fmt.Printf("✅ 已建立 %d 条知识的索引\n", len(index))
 
 	// =============== 3. 提问（检索 + 生成） ===============
-	query := "Eino 的 Stream 是怎么实现的？"
-	contextDocs := retrieveTopK(index, query, 2)
+	contextDocs := retrieveTopK(index, *query, 2)
 	contextText := formatContext(contextDocs)
 
 	messages := []*schema.Message{
-		schema.SystemMessage("你是一个AI助手。请结合提供的知识回答用户问题，如果知识不足以回答，请直接说明不知道。"),
+		schema.SystemMessage("你是一个AI助手。请结合提供的知识回答用户问题，如果知识不足以回答，请直接说明不知道。引用带来源的知识时请注明来源。"),
 		schema.SystemMessage("检索到的相关知识：\n" + contextText),
-		schema.UserMessage(query),
+		schema.UserMessage(*query),
 	}
 
 	resp, err := chat.Generate(ctx, messages)
@@ -60,12 +83,18 @@ func main() {
 		log.Fatalf("生成回答失败: %v", err)
 	}
 
-	fmt.Println("\n🤖 问题:", query)
+	fmt.Println("\n🤖 问题:", *query)
 	fmt.Println("💬 模型回答:", resp.Content)
+	for _, doc := range contextDocs {
+		if doc.Source != "" {
+			fmt.Println("📎 参考图片:", doc.Source)
+		}
+	}
 }
 
 type knowledgeDoc struct {
 	Text   string
+	Source string // 来源路径，内置知识为空
 	Vector map[string]float64
 }
 
@@ -74,14 +103,14 @@ func buildKnowledgeIndex(docs []string) []knowledgeDoc {
 	for i, doc := range docs {
 		index[i] = knowledgeDoc{
 			Text:   doc,
-			Vector: textToVector(doc),
+			Vector: textvec.FromText(doc),
 		}
 	}
 	return index
 }
 
 func retrieveTopK(index []knowledgeDoc, query string, k int) []knowledgeDoc {
-	qVec := textToVector(query)
+	qVec := textvec.FromText(query)
 	type scored struct {
 		doc   knowledgeDoc
 		score float64
@@ -89,7 +118,7 @@ func retrieveTopK(index []knowledgeDoc, query string, k int) []knowledgeDoc {
 
 	scoredDocs := make([]scored, 0, len(index))
 	for _, doc := range index {
-		score := cosineSimilarity(qVec, doc.Vector)
+		score := textvec.Cosine(qVec, doc.Vector)
 		scoredDocs = append(scoredDocs, scored{doc: doc, score: score})
 	}
 
@@ -117,80 +146,20 @@ func formatContext(docs []knowledgeDoc) string {
 	}
 	var builder strings.Builder
 	for i, doc := range docs {
+		if doc.Source != "" {
+			builder.WriteString(fmt.Sprintf("%d. %s（来源：%s）\n", i+1, doc.Text, doc.Source))
+			continue
+		}
 		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, doc.Text))
 	}
 	return builder.String()
 }
 
-func textToVector(text string) map[string]float64 {
-	tokens := tokenize(text)
-	if len(tokens) == 0 {
-		return map[string]float64{}
-	}
-
-	vec := make(map[string]float64)
-	for _, token := range tokens {
-		vec[token]++
-	}
-
-	total := float64(len(tokens))
-	for key := range vec {
-		vec[key] /= total
-	}
-	return vec
-}
-
-func tokenize(text string) []string {
-	normalized := strings.ToLower(text)
-	normalized = strings.NewReplacer(
-		"。", " ",
-		"，", " ",
-		"、", " ",
-		"；", " ",
-		"：", " ",
-		"！", " ",
-		"？", " ",
-		".", " ",
-		",", " ",
-		";", " ",
-		":", " ",
-		"!", " ",
-		"?", " ",
-	).Replace(normalized)
-
-	fields := strings.Fields(normalized)
-	result := make([]string, 0, len(fields))
-	for _, f := range fields {
-		if f != "" {
-			result = append(result, f)
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
 		}
 	}
-	return result
-}
-
-func cosineSimilarity(a, b map[string]float64) float64 {
-	if len(a) == 0 || len(b) == 0 {
-		return 0
-	}
-
-	var dot float64
-	var normA float64
-	var normB float64
-
-	for key, av := range a {
-		normA += av * av
-		if bv, ok := b[key]; ok {
-			dot += av * bv
-		}
-	}
-
-	for _, bv := range b {
-		normB += bv * bv
-	}
-
-	if normA == 0 || normB == 0 {
-		return 0
-	}
-
-	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+	return ""
 }
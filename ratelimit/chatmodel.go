@@ -0,0 +1,211 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"agent-demo/tokens"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	goopenai "github.com/meguminnnnnnnnn/go-openai"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// ChatModel 在调用前按 RPM/TPM 排队，收到 429 时等待 Retry-After 后重试，
+// 调用完成后用实际 token 用量校正限额
+type ChatModel struct {
+	inner   model.ToolCallingChatModel
+	limiter *Limiter
+}
+
+var _ model.ToolCallingChatModel = (*ChatModel)(nil)
+
+func Wrap(m model.ToolCallingChatModel, limiter *Limiter) *ChatModel {
+	return &ChatModel{inner: m, limiter: limiter}
+}
+
+// 创建带限流的 ark 模型：HTTP 层截获 429 的 Retry-After，并关闭 SDK 自身的重试，由限流器统一处理
+func NewArkChatModel(ctx context.Context, cfg *ark.ChatModelConfig, limit Config) (*ChatModel, error) {
+	return NewArkChatModelWithLimiter(ctx, cfg, NewLimiter(limit))
+}
+
+// 与 NewArkChatModel 相同，但使用给定的限流器，便于多个实例共享同一模型的额度
+func NewArkChatModelWithLimiter(ctx context.Context, cfg *ark.ChatModelConfig, limiter *Limiter) (*ChatModel, error) {
+	c := *cfg
+	if c.HTTPClient == nil {
+		timeout := 10 * time.Minute
+		if c.Timeout != nil {
+			timeout = *c.Timeout
+		}
+		c.HTTPClient = &http.Client{Timeout: timeout}
+	} else {
+		client := *c.HTTPClient
+		c.HTTPClient = &client
+	}
+	c.HTTPClient.Transport = limiter.Transport(c.HTTPClient.Transport)
+	if c.RetryTimes == nil {
+		noRetry := 0
+		c.RetryTimes = &noRetry
+	}
+	m, err := ark.NewChatModel(ctx, &c)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(m, limiter), nil
+}
+
+// 创建带限流的 OpenAI 兼容模型，同样在 HTTP 层截获 429 的 Retry-After（该客户端自身不重试）
+func NewOpenAIChatModelWithLimiter(ctx context.Context, cfg *openai.ChatModelConfig, limiter *Limiter) (*ChatModel, error) {
+	c := *cfg
+	if c.HTTPClient == nil {
+		c.HTTPClient = &http.Client{Timeout: c.Timeout}
+	} else {
+		client := *c.HTTPClient
+		c.HTTPClient = &client
+	}
+	c.HTTPClient.Transport = limiter.Transport(c.HTTPClient.Transport)
+	m, err := openai.NewChatModel(ctx, &c)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(m, limiter), nil
+}
+
+func (m *ChatModel) Limiter() *Limiter {
+	return m.limiter
+}
+
+func (m *ChatModel) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	estimate := estimateRequest(in, opts)
+	var resp *schema.Message
+	err := m.do(ctx, estimate, func() error {
+		var err error
+		resp, err = m.inner.Generate(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.ResponseMeta != nil && resp.ResponseMeta.Usage != nil {
+		m.limiter.Adjust(resp.ResponseMeta.Usage.TotalTokens - estimate)
+	}
+	return resp, nil
+}
+
+// 流式调用只在建立连接时处理 429；用量出现在流中（通常是最后一个 chunk）时再校正
+func (m *ChatModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	estimate := estimateRequest(in, opts)
+	var sr *schema.StreamReader[*schema.Message]
+	err := m.do(ctx, estimate, func() error {
+		var err error
+		sr, err = m.inner.Stream(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	adjusted := false
+	return schema.StreamReaderWithConvert(sr, func(chunk *schema.Message) (*schema.Message, error) {
+		if !adjusted && chunk.ResponseMeta != nil && chunk.ResponseMeta.Usage != nil && chunk.ResponseMeta.Usage.TotalTokens > 0 {
+			adjusted = true
+			m.limiter.Adjust(chunk.ResponseMeta.Usage.TotalTokens - estimate)
+		}
+		return chunk, nil
+	}), nil
+}
+
+func (m *ChatModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	bound, err := m.inner.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(bound, m.limiter), nil
+}
+
+func (m *ChatModel) GetType() string {
+	return "RateLimited"
+}
+
+// 回调由被包装的模型自己触发，避免框架在外层重复注入
+func (m *ChatModel) IsCallbacksEnabled() bool {
+	return components.IsCallbacksEnabled(m.inner)
+}
+
+// 排队取得额度后调用；429 时优先等待 Retry-After，没有则指数退避，重试次数用尽后返回最后的错误
+func (m *ChatModel) do(ctx context.Context, estimate int, call func() error) error {
+	retries := m.limiter.Config().retries()
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if _, err := m.limiter.Wait(ctx, estimate); err != nil {
+			return err
+		}
+		err := call()
+		if err == nil || !IsRateLimited(err) {
+			return err
+		}
+		retry := attempt < retries
+		m.limiter.observeRateLimited(retry)
+		if !retry {
+			return err
+		}
+		// 被拒绝的请求没有消耗上游额度，但仍然按已消耗计，下一次等待会更保守
+		if left := m.limiter.cooldownLeft(); left <= 0 {
+			m.limiter.Cooldown(backoff)
+			log.Printf("⏳ 模型限流（429），%s 后重试", backoff)
+			backoff *= 2
+		} else {
+			log.Printf("⏳ 模型限流（429），按 Retry-After %s 后重试", left.Round(time.Millisecond))
+		}
+	}
+}
+
+// IsRateLimited 判断错误是否为上游返回的 429
+func IsRateLimited(err error) bool {
+	var apiErr *arkmodel.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var reqErr *arkmodel.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var oaiAPIErr *goopenai.APIError
+	if errors.As(err, &oaiAPIErr) {
+		return oaiAPIErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	var oaiReqErr *goopenai.RequestError
+	if errors.As(err, &oaiReqErr) {
+		return oaiReqErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// 预估本次请求的 token：输入按字符粗算，输出按 MaxTokens 或默认预留
+func estimateRequest(in []*schema.Message, opts []model.Option) int {
+	total := tokens.DefaultOutputReserve
+	if o := model.GetCommonOptions(&model.Options{}, opts...); o.MaxTokens != nil {
+		total = *o.MaxTokens
+	}
+	for _, msg := range in {
+		total += tokens.MessageOverhead + tokens.Estimate(msg.Content)
+		for _, part := range msg.MultiContent {
+			switch part.Type {
+			case schema.ChatMessagePartTypeText:
+				total += tokens.Estimate(part.Text)
+			case schema.ChatMessagePartTypeImageURL:
+				total += tokens.ImageEstimate
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			total += tokens.Estimate(call.Function.Arguments)
+		}
+	}
+	return total
+}
@@ -0,0 +1,288 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrWaitTooLong 表示预计排队时间超过了 Config.MaxWait
+var ErrWaitTooLong = errors.New("限流排队时间过长")
+
+// 单个模型的限额：RPM 为每分钟请求数，TPM 为每分钟 token 数，0 表示不限制
+type Config struct {
+	RPM int `yaml:"rpm" json:"rpm"`
+	TPM int `yaml:"tpm" json:"tpm"`
+	// 收到 429 后最多重试的次数，0 使用默认值 3，负数表示不重试
+	MaxRetries int `yaml:"max_retries" json:"max_retries"`
+	// 预计排队超过该时长时直接返回 ErrWaitTooLong，0 表示一直等到 ctx 结束
+	MaxWaitMS int `yaml:"max_wait_ms" json:"max_wait_ms"`
+}
+
+func (c Config) retries() int {
+	switch {
+	case c.MaxRetries < 0:
+		return 0
+	case c.MaxRetries == 0:
+		return 3
+	default:
+		return c.MaxRetries
+	}
+}
+
+// 从 ARK_RPM / ARK_TPM 环境变量读取限额，供各个 Demo 共用
+func ConfigFromEnv() (Config, error) {
+	var cfg Config
+	for _, item := range []struct {
+		env string
+		dst *int
+	}{{"ARK_RPM", &cfg.RPM}, {"ARK_TPM", &cfg.TPM}} {
+		raw := strings.TrimSpace(os.Getenv(item.env))
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.Atoi(raw)
+		if err != nil || v < 0 {
+			return cfg, fmt.Errorf("环境变量 %s 必须是非负整数，当前 %q", item.env, raw)
+		}
+		*item.dst = v
+	}
+	return cfg, nil
+}
+
+// 令牌桶：容量为一分钟的额度，按秒匀速补充
+type bucket struct {
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b.capacity <= 0 {
+		return
+	}
+	b.tokens = math.Min(b.capacity, b.tokens+now.Sub(b.last).Seconds()*b.capacity/60)
+	b.last = now
+}
+
+// 距离桶里攒够 n 个令牌还需等待的时间
+func (b *bucket) wait(n float64) time.Duration {
+	if b.capacity <= 0 || b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) * 60 / b.capacity * float64(time.Second))
+}
+
+func (b *bucket) resize(capacity int, now time.Time) {
+	b.refill(now)
+	c := float64(capacity)
+	if b.capacity <= 0 {
+		// 新开启限额时从满桶开始
+		b.tokens = c
+	}
+	b.capacity = c
+	b.tokens = math.Min(b.tokens, c)
+	b.last = now
+}
+
+// Limiter 是一个模型的 RPM/TPM 限流器。请求按到达顺序排队，
+// 排队期间 ctx 结束即放弃；收到 429 时按 Retry-After 暂停所有请求
+type Limiter struct {
+	turn chan struct{} // 容量为 1，持有者才能从桶里取令牌，保证先到先得
+
+	mu            sync.Mutex
+	cfg           Config
+	requests      bucket
+	tokens        bucket
+	cooldownUntil time.Time
+
+	stats Stats
+}
+
+// 限流统计
+type Stats struct {
+	Requests    int64
+	Queued      int64 // 需要排队的请求数
+	TotalWait   time.Duration
+	MaxWait     time.Duration
+	Tokens      int64 // 按实际用量校正后的 token 数
+	RateLimited int64 // 收到 429 的次数
+	Retries     int64
+}
+
+func (s Stats) String() string {
+	avg := time.Duration(0)
+	if s.Queued > 0 {
+		avg = s.TotalWait / time.Duration(s.Queued)
+	}
+	return fmt.Sprintf("请求 %d 次，排队 %d 次（平均 %s，最长 %s），消耗 %d tokens，429 %d 次，重试 %d 次",
+		s.Requests, s.Queued, avg.Round(time.Millisecond), s.MaxWait.Round(time.Millisecond), s.Tokens, s.RateLimited, s.Retries)
+}
+
+func NewLimiter(cfg Config) *Limiter {
+	l := &Limiter{turn: make(chan struct{}, 1)}
+	l.SetConfig(cfg)
+	return l
+}
+
+// 更新限额，已攒下的令牌不超过新容量；配置热加载时调用
+func (l *Limiter) SetConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.cfg = cfg
+	l.requests.resize(cfg.RPM, now)
+	l.tokens.resize(cfg.TPM, now)
+}
+
+func (l *Limiter) Config() Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg
+}
+
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.stats
+}
+
+// 排队等待一次请求的额度，tokens 为预估的输入+输出 token 数；返回实际等待的时长
+func (l *Limiter) Wait(ctx context.Context, tokens int) (time.Duration, error) {
+	start := time.Now()
+	select {
+	case l.turn <- struct{}{}:
+	case <-ctx.Done():
+		return time.Since(start), ctx.Err()
+	}
+	defer func() { <-l.turn }()
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.requests.refill(now)
+		l.tokens.refill(now)
+		need := float64(tokens)
+		if l.tokens.capacity > 0 {
+			// 单个请求超过整分钟额度时按满桶计，否则永远等不到
+			need = math.Min(need, l.tokens.capacity)
+		}
+		wait := max(l.requests.wait(1), l.tokens.wait(need), l.cooldownUntil.Sub(now))
+		if wait <= 0 {
+			if l.requests.capacity > 0 {
+				l.requests.tokens--
+			}
+			if l.tokens.capacity > 0 {
+				l.tokens.tokens -= need
+			}
+			waited := time.Since(start)
+			l.stats.Requests++
+			l.stats.Tokens += int64(tokens)
+			if waited > time.Millisecond {
+				l.stats.Queued++
+				l.stats.TotalWait += waited
+				l.stats.MaxWait = max(l.stats.MaxWait, waited)
+			}
+			l.mu.Unlock()
+			return waited, nil
+		}
+		maxWait := time.Duration(l.cfg.MaxWaitMS) * time.Millisecond
+		l.mu.Unlock()
+
+		if maxWait > 0 && time.Since(start)+wait > maxWait {
+			return time.Since(start), fmt.Errorf("%w：预计还需 %s", ErrWaitTooLong, wait.Round(time.Millisecond))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return time.Since(start), ctx.Err()
+		}
+	}
+}
+
+// 用实际用量校正预估：多扣的退回，少扣的补扣（可以扣成负数，后续请求随之多等）
+func (l *Limiter) Adjust(delta int) {
+	if delta == 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.Tokens += int64(delta)
+	if l.tokens.capacity <= 0 {
+		return
+	}
+	l.tokens.refill(time.Now())
+	l.tokens.tokens = math.Min(l.tokens.capacity, l.tokens.tokens-float64(delta))
+}
+
+// 收到 429：在 d 之内暂停所有请求
+func (l *Limiter) Cooldown(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.cooldownUntil) {
+		l.cooldownUntil = until
+	}
+}
+
+func (l *Limiter) cooldownLeft() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return time.Until(l.cooldownUntil)
+}
+
+func (l *Limiter) observeRateLimited(retry bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stats.RateLimited++
+	if retry {
+		l.stats.Retries++
+	}
+}
+
+// Transport 包装 HTTP 传输层，在 429 响应上读取 Retry-After 并暂停该模型的所有请求；
+// SDK 返回的错误里拿不到响应头，只能在这一层截获
+func (l *Limiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &retryAfterTransport{base: base, limiter: l}
+}
+
+type retryAfterTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		if d := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); d > 0 {
+			t.limiter.Cooldown(d)
+		}
+	}
+	return resp, err
+}
+
+// Retry-After 可以是秒数，也可以是 HTTP 日期
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"2":                             2 * time.Second,
+		" 0.5 ":                         500 * time.Millisecond,
+		"Wed, 05 Mar 2025 10:00:30 GMT": 30 * time.Second,
+		"soon":                          0,
+	}
+	for in, want := range cases {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s，期望 %s", in, got, want)
+		}
+	}
+}
+
+func TestWaitRequestBucket(t *testing.T) {
+	l := NewLimiter(Config{RPM: 60, MaxWaitMS: 50})
+	ctx := context.Background()
+	// 新开启限额时是满桶，一分钟的额度可以立即用完
+	for i := range 60 {
+		if waited, err := l.Wait(ctx, 0); err != nil || waited > 20*time.Millisecond {
+			t.Fatalf("第 %d 个请求应立即放行，等待 %s，错误 %v", i+1, waited, err)
+		}
+	}
+	// 每秒补充 1 个，超过 max_wait_ms 时直接放弃
+	if _, err := l.Wait(ctx, 0); !errors.Is(err, ErrWaitTooLong) {
+		t.Fatalf("额度用完后应返回 ErrWaitTooLong，实际为 %v", err)
+	}
+	if s := l.Stats(); s.Requests != 60 {
+		t.Errorf("Stats.Requests = %d，期望 60", s.Requests)
+	}
+}
+
+func TestWaitTokenBucketAndAdjust(t *testing.T) {
+	l := NewLimiter(Config{TPM: 6000, MaxWaitMS: 50})
+	ctx := context.Background()
+	if _, err := l.Wait(ctx, 6000); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Wait(ctx, 1000); !errors.Is(err, ErrWaitTooLong) {
+		t.Fatalf("token 用完后应返回 ErrWaitTooLong，实际为 %v", err)
+	}
+	// 实际用量比预估少 3000，退回后可以继续请求
+	l.Adjust(-3000)
+	if _, err := l.Wait(ctx, 1000); err != nil {
+		t.Fatalf("退回多扣的 token 后应放行，实际为 %v", err)
+	}
+	if s := l.Stats(); s.Tokens != 4000 {
+		t.Errorf("Stats.Tokens = %d，期望 4000", s.Tokens)
+	}
+	// 少扣的补扣：扣成负数后即便请求很小也要等
+	l.Adjust(5000)
+	if _, err := l.Wait(ctx, 1); !errors.Is(err, ErrWaitTooLong) {
+		t.Fatalf("补扣后应继续限流，实际为 %v", err)
+	}
+}
+
+func TestWaitOversizedRequest(t *testing.T) {
+	l := NewLimiter(Config{TPM: 100, MaxWaitMS: 50})
+	// 超过整分钟额度的请求按满桶计，不会永远等下去
+	if _, err := l.Wait(context.Background(), 1000); err != nil {
+		t.Fatalf("超大请求应在满桶时放行，实际为 %v", err)
+	}
+}
+
+func TestSetConfigShrinksBucket(t *testing.T) {
+	l := NewLimiter(Config{RPM: 600, MaxWaitMS: 50})
+	l.SetConfig(Config{RPM: 1, MaxWaitMS: 50})
+	ctx := context.Background()
+	if _, err := l.Wait(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Wait(ctx, 0); !errors.Is(err, ErrWaitTooLong) {
+		t.Fatalf("缩小限额后已攒的额度不应超过新容量，实际为 %v", err)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	l := NewLimiter(Config{})
+	l.Cooldown(80 * time.Millisecond)
+	// 更短的冷却不会缩短已有的冷却
+	l.Cooldown(time.Millisecond)
+	waited, err := l.Wait(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if waited < 70*time.Millisecond {
+		t.Errorf("冷却期间应排队，实际只等了 %s", waited)
+	}
+
+	l.Cooldown(time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Wait(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("排队期间 ctx 超时应返回 DeadlineExceeded，实际为 %v", err)
+	}
+}
+
+func TestTransportReadsRetryAfter(t *testing.T) {
+	status := http.StatusTooManyRequests
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	get := func(l *Limiter) {
+		t.Helper()
+		resp, err := (&http.Client{Transport: l.Transport(nil)}).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	l := NewLimiter(Config{})
+	get(l)
+	if left := l.cooldownLeft(); left <= time.Second || left > 2*time.Second {
+		t.Errorf("429 后冷却剩余 %s，期望约 2s", left)
+	}
+
+	status = http.StatusOK
+	l = NewLimiter(Config{})
+	get(l)
+	if left := l.cooldownLeft(); left > 0 {
+		t.Errorf("非 429 响应不应触发冷却，剩余 %s", left)
+	}
+}
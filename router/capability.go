@@ -0,0 +1,154 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"agent-demo/tokens"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ErrNoCapableModel 表示没有任何已配置的模型能处理该请求（上下文过长、需要看图或调用工具等）
+var ErrNoCapableModel = errors.New("没有满足请求条件的模型")
+
+// 一次请求对模型能力的要求，根据完整消息列表、绑定的工具与调用参数估算
+type requirements struct {
+	InputTokens  int
+	OutputTokens int
+	// 调用方显式传了 max_tokens；否则按各 profile 的 params.max_tokens 预留输出
+	ExplicitOutput bool
+	Images         bool
+	Tools          bool
+}
+
+func (n *requirements) String() string {
+	parts := []string{fmt.Sprintf("输入约 %d tokens、输出 %d tokens", n.InputTokens, n.OutputTokens)}
+	if n.Images {
+		parts = append(parts, "包含图片")
+	}
+	if n.Tools {
+		parts = append(parts, "需要工具调用")
+	}
+	return strings.Join(parts, "，")
+}
+
+func (r *Router) requirementsOf(msgs []*schema.Message, opts []model.Option) *requirements {
+	need := &requirements{OutputTokens: tokens.DefaultOutputReserve, Tools: len(r.tools) > 0}
+	if o := model.GetCommonOptions(&model.Options{}, opts...); o.MaxTokens != nil {
+		need.OutputTokens = *o.MaxTokens
+		need.ExplicitOutput = true
+	}
+	for _, msg := range msgs {
+		need.InputTokens += tokens.MessageOverhead + tokens.Estimate(msg.Content) + tokens.Estimate(msg.ReasoningContent)
+		for _, part := range msg.MultiContent {
+			switch part.Type {
+			case schema.ChatMessagePartTypeText:
+				need.InputTokens += tokens.Estimate(part.Text)
+			case schema.ChatMessagePartTypeImageURL:
+				need.Images = true
+				need.InputTokens += tokens.ImageEstimate
+			}
+		}
+		for _, call := range msg.ToolCalls {
+			need.InputTokens += tokens.Estimate(call.Function.Name) + tokens.Estimate(call.Function.Arguments)
+		}
+	}
+	for _, tool := range r.tools {
+		need.InputTokens += tokens.Estimate(tool.Name) + tokens.Estimate(tool.Desc)
+		if tool.ParamsOneOf == nil {
+			continue
+		}
+		if s, err := tool.ParamsOneOf.ToJSONSchema(); err == nil {
+			if raw, err := json.Marshal(s); err == nil {
+				need.InputTokens += tokens.Estimate(string(raw))
+			}
+		}
+	}
+	return need
+}
+
+// 该 profile 实际会用到的输出 tokens：调用方没指定时套用 profile 的 params.max_tokens
+func (n *requirements) outputTokensFor(p *profileConfig) int {
+	if !n.ExplicitOutput && p.Params != nil && p.Params.MaxTokens != nil {
+		return *p.Params.MaxTokens
+	}
+	return n.OutputTokens
+}
+
+// 返回 profile 不满足要求的原因；未声明的能力视为不受限
+func (p *profileConfig) lacks(need *requirements) string {
+	if need == nil {
+		return ""
+	}
+	var reasons []string
+	output := need.outputTokensFor(p)
+	if p.ContextWindow > 0 && need.InputTokens+output > p.ContextWindow {
+		reasons = append(reasons, fmt.Sprintf("上下文窗口 %d 不足", p.ContextWindow))
+	}
+	if p.MaxOutputTokens > 0 && output > p.MaxOutputTokens {
+		reasons = append(reasons, fmt.Sprintf("最多输出 %d tokens", p.MaxOutputTokens))
+	}
+	if need.Images && p.Vision != nil && !*p.Vision {
+		reasons = append(reasons, "不支持图片")
+	}
+	if need.Tools && p.Tools != nil && !*p.Tools {
+		reasons = append(reasons, "不支持工具调用")
+	}
+	return strings.Join(reasons, "、")
+}
+
+func (t *routeTable) capable(profile string, need *requirements) bool {
+	p := t.cfg.Profiles[profile]
+	return p != nil && t.models[profile] != nil && p.lacks(need) == ""
+}
+
+// 路由选中的模型能力不足时自动改选：先沿它的回退链，再试默认 profile，
+// 最后按上下文窗口从小到大尝试其余 profile；都不满足时返回 ErrNoCapableModel
+func (t *routeTable) ensureCapable(decision Decision, need *requirements) (Decision, error) {
+	if t.capable(decision.Profile, need) {
+		return decision, nil
+	}
+	candidates := []string{decision.Profile}
+	if p := t.cfg.Profiles[decision.Profile]; p != nil {
+		candidates = append(candidates, p.Fallbacks...)
+	}
+	candidates = append(candidates, t.cfg.DefaultProfile)
+	rest := make([]string, 0, len(t.cfg.Profiles))
+	for name := range t.cfg.Profiles {
+		rest = append(rest, name)
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		wi, wj := t.cfg.Profiles[rest[i]].ContextWindow, t.cfg.Profiles[rest[j]].ContextWindow
+		if wi != wj {
+			// 0 表示未声明，排在最后
+			return wj == 0 || (wi != 0 && wi < wj)
+		}
+		return rest[i] < rest[j]
+	})
+	candidates = append(candidates, rest...)
+
+	why := "未配置模型"
+	if p := t.cfg.Profiles[decision.Profile]; p != nil && t.models[decision.Profile] != nil {
+		why = p.lacks(need)
+	}
+	for _, name := range candidates {
+		if t.capable(name, need) {
+			reason := fmt.Sprintf("%s；%s 能力不足（%s），改用 %s", decision.Reason, decision.Profile, why, name)
+			return Decision{Profile: name, Reason: reason}, nil
+		}
+	}
+
+	var details []string
+	for _, name := range rest {
+		if t.models[name] == nil {
+			continue
+		}
+		details = append(details, fmt.Sprintf("%s（%s）", name, t.cfg.Profiles[name].lacks(need)))
+	}
+	return decision, fmt.Errorf("%w：%s；%s", ErrNoCapableModel, need, strings.Join(details, "，"))
+}
@@ -0,0 +1,50 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestLacksUsesProfileMaxTokens(t *testing.T) {
+	small, large := 200, 4000
+	r := &Router{}
+	msgs := []*schema.Message{schema.UserMessage("你好")}
+
+	cases := []struct {
+		name    string
+		profile *profileConfig
+		opts    []model.Option
+		lacks   bool
+	}{
+		{
+			name:    "未设 params.max_tokens 时按默认预留计算",
+			profile: &profileConfig{ContextWindow: 1000},
+			lacks:   true,
+		},
+		{
+			name:    "params.max_tokens 较小时不再按默认预留拒绝",
+			profile: &profileConfig{ContextWindow: 1000, Params: &paramsConfig{MaxTokens: &small}},
+		},
+		{
+			name:    "params.max_tokens 超出上下文窗口时拒绝",
+			profile: &profileConfig{ContextWindow: 2000, Params: &paramsConfig{MaxTokens: &large}},
+			lacks:   true,
+		},
+		{
+			name:    "调用方传入的 max_tokens 优先",
+			profile: &profileConfig{ContextWindow: 1000, Params: &paramsConfig{MaxTokens: &small}},
+			opts:    []model.Option{model.WithMaxTokens(2000)},
+			lacks:   true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			why := tc.profile.lacks(r.requirementsOf(msgs, tc.opts))
+			if (why != "") != tc.lacks {
+				t.Errorf("lacks = %q，期望不满足 = %v", why, tc.lacks)
+			}
+		})
+	}
+}
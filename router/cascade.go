@@ -0,0 +1,316 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-demo/llm"
+	"agent-demo/ratelimit"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 级联路由：先用便宜的模型回答，置信度不足时逐级升级到更强的模型。
+// 置信度默认由模型在回答末尾自评；配置 verifier 后改由该 profile 审核答案打分
+type cascadeConfig struct {
+	Stages            []string `yaml:"stages" json:"stages"` // 由便宜到昂贵排列
+	MinConfidence     float64  `yaml:"min_confidence" json:"min_confidence"`
+	Verifier          string   `yaml:"verifier" json:"verifier"`
+	VerifierTimeoutMS int      `yaml:"verifier_timeout_ms" json:"verifier_timeout_ms"`
+}
+
+// 每千 token 的价格，单位与账单一致即可；级联策略用它估算相对始终使用最强模型节省的费用
+type priceConfig struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+func (c *cascadeConfig) validate(profiles map[string]*profileConfig) []error {
+	var errs []error
+	if len(c.Stages) < 2 {
+		errs = append(errs, fmt.Errorf("cascade.stages 至少需要两级"))
+	}
+	seen := make(map[string]bool)
+	for _, name := range c.Stages {
+		p, ok := profiles[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("cascade.stages 引用了未定义的 profile %q", name))
+			continue
+		}
+		if seen[name] {
+			errs = append(errs, fmt.Errorf("cascade.stages 中 %s 重复", name))
+		}
+		seen[name] = true
+		if p.Price == nil {
+			errs = append(errs, fmt.Errorf("级联 profile %s 需要配置 price 以统计节省的费用", name))
+		}
+	}
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		errs = append(errs, fmt.Errorf("cascade.min_confidence 必须在 [0,1] 之间，当前 %.2f", c.MinConfidence))
+	}
+	if c.Verifier != "" {
+		if p := profiles[c.Verifier]; p == nil || p.modelID == "" {
+			errs = append(errs, fmt.Errorf("cascade.verifier %q 未定义或未配置模型", c.Verifier))
+		}
+	}
+	if c.VerifierTimeoutMS <= 0 {
+		c.VerifierTimeoutMS = 10000
+	}
+	return errs
+}
+
+func (p *priceConfig) cost(usage *schema.TokenUsage) float64 {
+	if p == nil || usage == nil {
+		return 0
+	}
+	return (float64(usage.PromptTokens)*p.Input + float64(usage.CompletionTokens)*p.Output) / 1000
+}
+
+// 级联统计，跨配置热加载累计
+type cascadeStats struct {
+	mu           sync.Mutex
+	requests     int64
+	escalations  int64
+	answeredBy   map[string]int64
+	actualCost   float64
+	baselineCost float64
+	strongest    string
+}
+
+type CascadeStats struct {
+	Requests     int64
+	Escalations  int64
+	AnsweredBy   map[string]int64
+	ActualCost   float64 // 实际花费，含被升级丢弃的回答与审核调用
+	BaselineCost float64 // 同样的请求始终交给最强模型的估算花费
+	Strongest    string
+}
+
+func (s CascadeStats) String() string {
+	rate := 0.0
+	if s.Requests > 0 {
+		rate = float64(s.Escalations) * 100 / float64(s.Requests)
+	}
+	names := make([]string, 0, len(s.AnsweredBy))
+	for name := range s.AnsweredBy {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	dist := make([]string, 0, len(names))
+	for _, name := range names {
+		dist = append(dist, fmt.Sprintf("%s=%d", name, s.AnsweredBy[name]))
+	}
+	saved, savedRate := s.BaselineCost-s.ActualCost, 0.0
+	if s.BaselineCost > 0 {
+		savedRate = saved * 100 / s.BaselineCost
+	}
+	return fmt.Sprintf("级联请求 %d 次，升级 %d 次（%.1f%%），应答分布 [%s]；实际花费 %.4f，始终使用 %s 约需 %.4f，节省 %.4f（%.1f%%）",
+		s.Requests, s.Escalations, rate, strings.Join(dist, " "), s.ActualCost, s.Strongest, s.BaselineCost, saved, savedRate)
+}
+
+func (s *cascadeStats) add(answered, strongest string, escalated bool, actual, baseline float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.answeredBy == nil {
+		s.answeredBy = make(map[string]int64)
+	}
+	s.requests++
+	if escalated {
+		s.escalations++
+	}
+	s.answeredBy[answered]++
+	s.actualCost += actual
+	s.baselineCost += baseline
+	s.strongest = strongest
+}
+
+func (s *cascadeStats) snapshot() CascadeStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := CascadeStats{
+		Requests:     s.requests,
+		Escalations:  s.escalations,
+		AnsweredBy:   make(map[string]int64, len(s.answeredBy)),
+		ActualCost:   s.actualCost,
+		BaselineCost: s.baselineCost,
+		Strongest:    s.strongest,
+	}
+	for k, v := range s.answeredBy {
+		out.AnsweredBy[k] = v
+	}
+	return out
+}
+
+// 已配置模型且能满足请求的级联层级；need 为 nil 时只看是否配置了模型
+func (t *routeTable) cascadeStages(need *requirements) []string {
+	var stages []string
+	for _, name := range t.cfg.Cascade.Stages {
+		if t.capable(name, need) {
+			stages = append(stages, name)
+		}
+	}
+	return stages
+}
+
+const selfRatePrompt = "回答完成后，请另起一行以“置信度：0.xx”的格式给出你对回答正确、完整的把握（0~1），没有把握时请如实给出较低的分数。"
+
+var confidenceLine = regexp.MustCompile(`(?m)^[ \t]*[\[【(（]?\s*(?:置信度|confidence)\s*[:：]\s*([01](?:\.\d+)?)\s*[\]】)）]?[ \t]*$`)
+
+// 取出回答末尾的自评置信度并从正文中删去；没有给出时返回 false
+func extractConfidence(content string) (string, float64, bool) {
+	locs := confidenceLine.FindAllStringSubmatchIndex(content, -1)
+	if len(locs) == 0 {
+		return content, 0, false
+	}
+	loc := locs[len(locs)-1]
+	v, err := strconv.ParseFloat(content[loc[2]:loc[3]], 64)
+	if err != nil {
+		return content, 0, false
+	}
+	return strings.TrimSpace(content[:loc[0]] + content[loc[1]:]), v, true
+}
+
+// 把自评要求追加到系统提示词末尾，不修改调用方的消息
+func withSelfRating(in []*schema.Message) []*schema.Message {
+	out := make([]*schema.Message, 0, len(in)+1)
+	if len(in) > 0 && in[0].Role == schema.System {
+		sys := *in[0]
+		sys.Content = strings.TrimSpace(sys.Content + "\n" + selfRatePrompt)
+		out = append(out, &sys)
+		return append(out, in[1:]...)
+	}
+	out = append(out, schema.SystemMessage(selfRatePrompt))
+	return append(out, in...)
+}
+
+type verdict struct {
+	Confidence float64 `json:"confidence"`
+	Reason     string  `json:"reason"`
+}
+
+// 让审核模型给答案打分，返回置信度与本次审核的花费；与回答一样经过熔断、回退链和 profile 的生成参数
+func (t *routeTable) verify(ctx context.Context, question, answer string) (verdict, float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.cfg.Cascade.VerifierTimeoutMS)*time.Millisecond)
+	defer cancel()
+
+	msgs := []*schema.Message{
+		schema.SystemMessage(`你是答案审核员。判断给出的回答是否正确、完整地解决了用户的问题，给出 0~1 的置信度。` +
+			`只输出 JSON，例如 {"confidence": 0.8, "reason": "一句话理由"}。`),
+		schema.UserMessage(fmt.Sprintf("【问题】\n%s\n\n【回答】\n%s", question, answer)),
+	}
+	var resp *schema.Message
+	rec, err := t.callChain(ctx, t.cfg.Cascade.Verifier, nil, nil, func(ctx context.Context, name string, m *ratelimit.ChatModel) error {
+		var err error
+		resp, err = t.withParams(name, m).Generate(ctx, msgs)
+		return err
+	})
+	if err != nil {
+		return verdict{}, 0, err
+	}
+	var cost float64
+	if resp.ResponseMeta != nil {
+		cost = t.cfg.Profiles[rec.Answered].Price.cost(resp.ResponseMeta.Usage)
+	}
+	var v verdict
+	if err := json.Unmarshal([]byte(llm.TrimJSONFence(resp.Content)), &v); err != nil {
+		return verdict{}, cost, fmt.Errorf("解析审核结果失败: %w", err)
+	}
+	return v, cost, nil
+}
+
+// 逐级调用：非最后一级的回答置信度达到阈值即返回，否则升级；带工具调用的回答无法评估，直接采用
+func (r *Router) cascadeGenerate(ctx context.Context, t *routeTable, in []*schema.Message, opts ...model.Option) (*schema.Message, Decision, *CallRecord, error) {
+	cfg := t.cfg.Cascade
+	need := r.requirementsOf(in, opts)
+	stages := t.cascadeStages(need)
+	record := &CallRecord{}
+	if len(stages) == 0 {
+		return nil, Decision{Profile: t.cfg.DefaultProfile, Reason: "cascade"}, record, fmt.Errorf("%w：%s；级联的各级 profile 均未配置模型或能力不足", ErrNoCapableModel, need)
+	}
+	record.Requested = stages[0]
+	strongest := stages[len(stages)-1]
+	question := r.inputOf(in).Query
+	selfRate := cfg.Verifier == ""
+
+	var (
+		actual float64
+		trail  []string
+		resp   *schema.Message
+	)
+	for i, stage := range stages {
+		last := i == len(stages)-1
+		msgs := in
+		if !last && selfRate {
+			msgs = withSelfRating(in)
+		}
+
+		var out *schema.Message
+		rec, err := t.callChain(ctx, stage, need, nil, func(ctx context.Context, name string, _ *ratelimit.ChatModel) error {
+			bound, err := r.bind(t, name)
+			if err != nil {
+				return err
+			}
+			out, err = bound.Generate(ctx, msgs, opts...)
+			return err
+		})
+		record.Attempts = append(record.Attempts, rec.Attempts...)
+		if err != nil {
+			// 这一级整条回退链都失败时，如果还有更强的级别就继续升级
+			if last || ctx.Err() != nil {
+				return nil, Decision{Profile: stage, Reason: "cascade:" + strings.Join(trail, "->")}, record, err
+			}
+			trail = append(trail, stage+"(失败)")
+			continue
+		}
+		record.Answered = rec.Answered
+		var usage *schema.TokenUsage
+		if out.ResponseMeta != nil {
+			usage = out.ResponseMeta.Usage
+		}
+		actual += t.cfg.Profiles[rec.Answered].Price.cost(usage)
+		resp = out
+
+		if last || len(out.ToolCalls) > 0 {
+			trail = append(trail, stage)
+			baseline := t.cfg.Profiles[strongest].Price.cost(usage)
+			r.cascade.add(stage, strongest, i > 0, actual, baseline)
+			break
+		}
+
+		var confidence float64
+		if selfRate {
+			var ok bool
+			out.Content, confidence, ok = extractConfidence(out.Content)
+			if !ok {
+				log.Printf("⚠️ %s 的回答没有给出置信度，按 0 处理", stage)
+			}
+		} else {
+			v, cost, err := t.verify(ctx, question, out.Content)
+			actual += cost
+			if err != nil {
+				log.Printf("⚠️ 审核 %s 的回答失败，按 0 处理: %v", stage, err)
+			}
+			confidence = v.Confidence
+		}
+		trail = append(trail, fmt.Sprintf("%s(%.2f)", stage, confidence))
+		if confidence >= cfg.MinConfidence {
+			baseline := t.cfg.Profiles[strongest].Price.cost(usage)
+			r.cascade.add(stage, strongest, i > 0, actual, baseline)
+			break
+		}
+		log.Printf("⬆️ %s 置信度 %.2f 低于 %.2f，升级到下一级", stage, confidence, cfg.MinConfidence)
+	}
+
+	decision := Decision{Profile: record.Answered, Reason: "cascade:" + strings.Join(trail, "->")}
+	annotate(resp, decision, record)
+	return resp, decision, record, nil
+}
@@ -0,0 +1,43 @@
+package router
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 审核调用与回答一样套用 profile 的生成参数，带 ```json 代码块的审核结果也能解析
+func TestCascadeVerifierUsesProfileParams(t *testing.T) {
+	r := newMockRouter(t, `
+strategy: cascade
+default_profile: strong
+profiles:
+  cheap:
+    model: cheap-model
+    price: {input: 0.1, output: 0.2}
+  strong:
+    model: strong-model
+    price: {input: 1, output: 2}
+  judge:
+    model: judge-model
+    params:
+      system_prefix: 严格审核
+cascade:
+  stages: [cheap, strong]
+  min_confidence: 0.8
+  verifier: judge
+  verifier_timeout_ms: 1000
+`, "rules:\n"+
+		"  - model: judge-model\n    system: 严格审核\n    content: \"```json\\n{\\\"confidence\\\": 0.9}\\n```\"\n"+
+		"  - model: judge-model\n    content: '{\"confidence\": 0.1}'\n"+
+		"  - content: 回答\n")
+
+	resp, decision, record, err := r.GenerateWithRecord(context.Background(), []*schema.Message{schema.UserMessage("一加一等于几")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Answered != "cheap" || resp.Content != "回答" {
+		t.Errorf("审核通过时应由 cheap 应答，实际为 %s（%s）", record.Answered, decision.Reason)
+	}
+}
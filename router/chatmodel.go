@@ -0,0 +1,448 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"agent-demo/llm"
+	"agent-demo/ratelimit"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Router 把路由表包装成 Eino 的 ToolCallingChatModel：每次调用先按策略选出 profile，
+// 再沿回退链委托给对应的模型（带限流的 ark.ChatModel），可以直接放进 compose 图或 Agent 里替换单个模型
+type Router struct {
+	path    string
+	pool    *modelPool
+	table   *atomic.Pointer[routeTable]
+	tools   []*schema.ToolInfo
+	hedge   *hedgeStats
+	cascade *cascadeStats
+	ab      *experimentStats
+	shadows *shadowRunner
+}
+
+var _ model.ToolCallingChatModel = (*Router)(nil)
+
+// 响应消息 Extra 中记录路由结果的键
+const (
+	ExtraKeyProfile = "router_profile"
+	ExtraKeyReason  = "router_reason"
+	ExtraKeyVariant = "router_variant" // 参与 A/B 实验时为“实验/variant”
+)
+
+// ErrUnknownProfile 表示 WithProfile 指定的 profile 未在路由配置中定义
+var ErrUnknownProfile = errors.New("未定义的 profile")
+
+type profileKey struct{}
+
+// WithProfile 让本次调用跳过路由策略与 A/B 实验，直接使用指定的 profile；
+// 能力不足时仍会按回退链改选，模型失败时仍会沿回退链重试
+func WithProfile(ctx context.Context, profile string) context.Context {
+	return context.WithValue(ctx, profileKey{}, profile)
+}
+
+func profileFrom(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(profileKey{}).(string)
+	return p, ok && p != ""
+}
+
+// 加载并校验路由配置，按 profile 创建模型实例；base 给出模型服务与连接信息，其中的模型 ID 被忽略
+func New(ctx context.Context, configPath string, base llm.Config) (*Router, error) {
+	pool := newModelPool(base)
+	cfg, err := loadRouterConfig(configPath, base.Provider)
+	if err != nil {
+		return nil, err
+	}
+	t, err := buildRouteTable(ctx, cfg, pool)
+	if err != nil {
+		return nil, err
+	}
+	r := &Router{path: configPath, pool: pool, table: &atomic.Pointer[routeTable]{}, hedge: &hedgeStats{}, cascade: &cascadeStats{}, ab: &experimentStats{}, shadows: &shadowRunner{}}
+	r.table.Store(t)
+	return r, nil
+}
+
+// 从环境变量读取模型服务（见 llm.ConfigFromEnv），未配置任何服务时使用离线 mock 模型
+func NewFromEnv(ctx context.Context, configPath string) (*Router, error) {
+	base, err := llm.ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, configPath, base)
+}
+
+// 轮询配置文件，变化后重新加载；新配置校验通过才替换，失败时保留旧路由表。阻塞直到 ctx 结束
+func (r *Router) Watch(ctx context.Context, interval time.Duration) {
+	watchConfig(ctx, r.path, interval, func() {
+		cfg, err := loadRouterConfig(r.path, r.pool.base.Provider)
+		if err != nil {
+			log.Printf("⚠️ 路由配置重载失败，继续使用旧配置: %v", err)
+			return
+		}
+		t, err := buildRouteTable(ctx, cfg, r.pool)
+		if err != nil {
+			log.Printf("⚠️ 路由配置重载失败，继续使用旧配置: %v", err)
+			return
+		}
+		r.table.Store(t)
+		log.Printf("🔄 路由配置已重新加载\n%s", r.Summary())
+	})
+}
+
+func (r *Router) Strategy() string {
+	return r.table.Load().cfg.Strategy
+}
+
+// 已配置模型的 profile 名，按名称排序；可作为 WithProfile 的参数
+func (r *Router) Profiles() []string {
+	t := r.table.Load()
+	names := make([]string, 0, len(t.models))
+	for name := range t.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 当前生效的 profile 与规则，便于启动和重载时打印
+func (r *Router) Summary() string {
+	t := r.table.Load()
+	names := make([]string, 0, len(t.cfg.Profiles))
+	for name := range t.cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var builder strings.Builder
+	for _, name := range names {
+		p := t.cfg.Profiles[name]
+		if p.modelID == "" {
+			continue
+		}
+		if params := p.Params.String(); params != "" {
+			builder.WriteString(fmt.Sprintf("✅ 模型实例化成功：%s -> %s（%s）\n", name, p.modelID, params))
+		} else {
+			builder.WriteString(fmt.Sprintf("✅ 模型实例化成功：%s -> %s\n", name, p.modelID))
+		}
+	}
+	builder.WriteString(fmt.Sprintf("🔌 模型服务：%s\n", t.pool.base.Provider))
+	builder.WriteString(fmt.Sprintf("🧭 路由策略：%s\n", t.cfg.Strategy))
+	if t.cfg.Strategy == strategyCascade {
+		builder.WriteString(fmt.Sprintf("🪜 级联顺序：%s（置信度阈值 %.2f）\n", strings.Join(t.cascadeStages(nil), " -> "), t.cfg.Cascade.MinConfidence))
+	}
+	for _, rule := range t.cfg.Rules {
+		builder.WriteString(fmt.Sprintf("📜 规则 %s（优先级 %d）-> %s\n", rule.Name, rule.Priority, rule.Profile))
+	}
+	return builder.String()
+}
+
+func (r *Router) HedgeStats() HedgeStats {
+	return r.hedge.snapshot()
+}
+
+func (r *Router) CascadeStats() CascadeStats {
+	return r.cascade.snapshot()
+}
+
+// 各模型 ID 的限流排队与 429 统计
+func (r *Router) RateLimitStats() map[string]ratelimit.Stats {
+	return r.pool.limiterStats()
+}
+
+func (r *Router) ShadowStats() ShadowStats {
+	return r.shadows.snapshot()
+}
+
+// 各 A/B variant 的请求、失败、耗时与反馈统计
+func (r *Router) ExperimentStats() []VariantStats {
+	return r.ab.snapshot()
+}
+
+// 为 subject 最近看到的回答记录一次好评或差评，返回其所属 variant；分配是稳定的，无需保存请求记录
+func (r *Router) Feedback(subject string, positive bool) (string, error) {
+	exp := r.table.Load().cfg.Experiment
+	if exp == nil {
+		return "", errors.New("当前未配置 A/B 实验")
+	}
+	if subject == "" {
+		return "", errors.New("反馈需要提供用户或会话 ID")
+	}
+	v := exp.assign(subject)
+	r.ab.feedback(exp.Name, v.Name, positive)
+	return v.Name, nil
+}
+
+// 只做路由决策，不调用模型
+func (r *Router) Route(ctx context.Context, msgs []*schema.Message) Decision {
+	t := r.table.Load()
+	_, forced := profileFrom(ctx)
+	if t.cfg.Strategy == strategyCascade && !forced {
+		return t.route(ctx, r.inputOf(msgs))
+	}
+	decision, _, err := r.decide(ctx, t, msgs, r.requirementsOf(msgs, nil))
+	if err != nil {
+		decision.Reason += "（" + err.Error() + "）"
+	}
+	return decision
+}
+
+// 选出本次调用的 profile：ctx 指定了 profile 时直接使用，否则按策略路由并套用 A/B 实验与组内加权；
+// 最后检查模型能力，不足时改选。返回的 variant 不为空表示请求参与了 A/B 实验
+func (r *Router) decide(ctx context.Context, t *routeTable, in []*schema.Message, need *requirements) (Decision, string, error) {
+	if name, ok := profileFrom(ctx); ok {
+		if _, defined := t.cfg.Profiles[name]; !defined {
+			return Decision{Profile: name, Reason: "指定 profile"}, "", fmt.Errorf("%w：%s", ErrUnknownProfile, name)
+		}
+		decision, err := t.ensureCapable(Decision{Profile: name, Reason: "指定 profile"}, need)
+		return decision, "", err
+	}
+	decision, variant := t.applyExperiment(ctx, t.route(ctx, r.inputOf(in)))
+	decision, err := t.ensureCapable(t.balance(decision, need), need)
+	return decision, variant, err
+}
+
+// 与 Generate 相同，额外返回路由决策与调用链路。ctx 带有 WithSubject 时按 A/B 实验分配模型（级联策略除外）
+func (r *Router) GenerateWithRecord(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, Decision, *CallRecord, error) {
+	t := r.table.Load()
+	start := time.Now()
+	if _, forced := profileFrom(ctx); t.cfg.Strategy == strategyCascade && !forced {
+		resp, decision, record, err := r.cascadeGenerate(ctx, t, in, opts...)
+		if err == nil {
+			r.shadow(ctx, t, in, opts, resp, decision, record, time.Since(start))
+		}
+		return resp, decision, record, err
+	}
+	need := r.requirementsOf(in, opts)
+	decision, variant, err := r.decide(ctx, t, in, need)
+	if err != nil {
+		return nil, decision, &CallRecord{Requested: decision.Profile}, err
+	}
+	resp, record, err := r.generate(ctx, t, decision, need, in, opts...)
+	latency := time.Since(start)
+	if variant != "" {
+		r.ab.observe(t.cfg.Experiment.Name, variant, latency, err)
+		tagged := t.cfg.Experiment.Name + "/" + variant
+		if resp != nil {
+			resp.Extra[ExtraKeyVariant] = tagged
+		}
+		if record != nil {
+			record.Variant = tagged
+		}
+	}
+	if err == nil {
+		r.shadow(ctx, t, in, opts, resp, decision, record, latency)
+	}
+	return resp, decision, record, err
+}
+
+func (r *Router) generate(ctx context.Context, t *routeTable, decision Decision, need *requirements, in []*schema.Message, opts ...model.Option) (*schema.Message, *CallRecord, error) {
+	if secondary, ok := t.hedgePartner(decision.Profile, need); ok {
+		resp, record, err := r.hedgedGenerate(ctx, t, decision.Profile, secondary, in, opts...)
+		if err == nil {
+			annotate(resp, decision, record)
+			return resp, record, nil
+		}
+		if ctx.Err() != nil {
+			return nil, record, err
+		}
+		log.Printf("⚠️ 对冲请求失败，改走回退链: %v", err)
+	}
+
+	var resp *schema.Message
+	record, err := t.callChain(ctx, decision.Profile, need, nil, func(ctx context.Context, name string, _ *ratelimit.ChatModel) error {
+		bound, err := r.bind(t, name)
+		if err != nil {
+			return err
+		}
+		resp, err = bound.Generate(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, record, err
+	}
+	annotate(resp, decision, record)
+	return resp, record, nil
+}
+
+// 对冲模式下以流式调用判断首 token，胜出后把整条流拼成完整消息
+func (r *Router) hedgedGenerate(ctx context.Context, t *routeTable, primary, secondary string, in []*schema.Message, opts ...model.Option) (*schema.Message, *CallRecord, error) {
+	sr, record, err := t.hedgedStream(ctx, primary, secondary, r.hedge, r.binder(t), in, opts...)
+	if err != nil {
+		return nil, record, err
+	}
+	defer sr.Close()
+	var chunks []*schema.Message
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, record, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	resp, err := schema.ConcatMessages(chunks)
+	return resp, record, err
+}
+
+func annotate(resp *schema.Message, decision Decision, record *CallRecord) {
+	if resp.Extra == nil {
+		resp.Extra = make(map[string]any)
+	}
+	resp.Extra[ExtraKeyProfile] = record.Answered
+	resp.Extra[ExtraKeyReason] = decision.Reason
+}
+
+func (r *Router) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	resp, _, _, err := r.GenerateWithRecord(ctx, in, opts...)
+	return resp, err
+}
+
+// 流式调用只在建立连接阶段回退；流开始后出错由调用方处理。
+// 级联策略需要拿到完整回答才能判断是否升级，因此先整体生成再以单个 chunk 的流返回
+func (r *Router) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	stream, _, _, err := r.StreamWithRecord(ctx, in, opts...)
+	return stream, err
+}
+
+// 与 Stream 相同，同时返回路由决策与调用链路；record.Answered 为实际建立流的 profile
+func (r *Router) StreamWithRecord(ctx context.Context, in []*schema.Message, opts ...model.Option) (stream *schema.StreamReader[*schema.Message], decision Decision, record *CallRecord, err error) {
+	t := r.table.Load()
+	if _, forced := profileFrom(ctx); t.cfg.Strategy == strategyCascade && !forced {
+		start := time.Now()
+		resp, decision, record, err := r.cascadeGenerate(ctx, t, in, opts...)
+		if err != nil {
+			return nil, decision, record, err
+		}
+		r.shadow(ctx, t, in, opts, resp, decision, record, time.Since(start))
+		log.Printf("🧭 流式请求路由到 %s（%s），链路：%s", record.Answered, decision.Reason, record)
+		return schema.StreamReaderFromArray([]*schema.Message{resp}), decision, record, nil
+	}
+	need := r.requirementsOf(in, opts)
+	decision, variant, err := r.decide(ctx, t, in, need)
+	if err != nil {
+		return nil, decision, nil, err
+	}
+	if variant != "" {
+		// 流式请求的耗时只统计到连接建立（对冲时为首个 token）；variant 标记在首个 chunk 上
+		start := time.Now()
+		defer func() {
+			r.ab.observe(t.cfg.Experiment.Name, variant, time.Since(start), err)
+		}()
+		tagged := t.cfg.Experiment.Name + "/" + variant
+		defer func() {
+			if stream != nil {
+				stream = tagFirstChunk(stream, ExtraKeyVariant, tagged)
+			}
+			if record != nil {
+				record.Variant = tagged
+			}
+		}()
+	}
+
+	if secondary, ok := t.hedgePartner(decision.Profile, need); ok {
+		hedged, record, err := t.hedgedStream(ctx, decision.Profile, secondary, r.hedge, r.binder(t), in, opts...)
+		if err == nil {
+			log.Printf("🧭 流式请求路由到 %s（%s），链路：%s", record.Answered, decision.Reason, record)
+			return r.shadowStream(ctx, t, in, opts, hedged, decision, record), decision, record, nil
+		}
+		if ctx.Err() != nil {
+			return nil, decision, record, err
+		}
+		log.Printf("⚠️ 对冲请求失败，改走回退链: %v", err)
+	}
+
+	closeStream := func() {
+		stream.Close()
+		stream = nil
+	}
+	record, err = t.callChain(ctx, decision.Profile, need, closeStream, func(ctx context.Context, name string, _ *ratelimit.ChatModel) error {
+		bound, err := r.bind(t, name)
+		if err != nil {
+			return err
+		}
+		stream, err = bound.Stream(ctx, in, opts...)
+		return err
+	})
+	if err != nil {
+		return nil, decision, record, err
+	}
+	stream = prependChunk(nil, stream, record.release)
+	log.Printf("🧭 流式请求路由到 %s（%s），链路：%s", record.Answered, decision.Reason, record)
+	return r.shadowStream(ctx, t, in, opts, stream, decision, record), decision, record, nil
+}
+
+func tagFirstChunk(sr *schema.StreamReader[*schema.Message], key string, value any) *schema.StreamReader[*schema.Message] {
+	first := true
+	return schema.StreamReaderWithConvert(sr, func(chunk *schema.Message) (*schema.Message, error) {
+		if first && chunk != nil {
+			first = false
+			cp := *chunk
+			cp.Extra = make(map[string]any, len(chunk.Extra)+1)
+			for k, v := range chunk.Extra {
+				cp.Extra[k] = v
+			}
+			cp.Extra[key] = value
+			return &cp, nil
+		}
+		return chunk, nil
+	})
+}
+
+// 返回绑定了工具的新 Router，与原 Router 共享路由表、模型实例与熔断状态
+func (r *Router) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	if len(tools) == 0 {
+		return nil, errors.New("no tools to bind")
+	}
+	cp := *r
+	cp.tools = tools
+	return &cp, nil
+}
+
+func (r *Router) GetType() string {
+	return "Router"
+}
+
+// 路由特征取自最后一条用户消息；任意消息带图片即视为多模态请求
+func (r *Router) inputOf(msgs []*schema.Message) routeInput {
+	in := routeInput{HasTools: len(r.tools) > 0}
+	for _, msg := range msgs {
+		for _, part := range msg.MultiContent {
+			if part.Type == schema.ChatMessagePartTypeImageURL {
+				in.HasImages = true
+			}
+		}
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != schema.User {
+			continue
+		}
+		in.Query = messageText(msgs[i])
+		break
+	}
+	return in
+}
+
+func messageText(msg *schema.Message) string {
+	if len(msg.MultiContent) == 0 {
+		return msg.Content
+	}
+	texts := []string{msg.Content}
+	for _, part := range msg.MultiContent {
+		if part.Type == schema.ChatMessagePartTypeText {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, "\n"))
+}
@@ -0,0 +1,207 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-demo/llm"
+	"agent-demo/ratelimit"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/schema"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// 意图分类路由：让快速模型从固定标签中选一个并给出置信度，
+// 置信度不足或调用失败时回退到关键词规则
+type classifierConfig struct {
+	Profile       string            `yaml:"profile" json:"profile"` // 用于分类的模型
+	MinConfidence float64           `yaml:"min_confidence" json:"min_confidence"`
+	TimeoutMS     int               `yaml:"timeout_ms" json:"timeout_ms"`
+	CacheSize     int               `yaml:"cache_size" json:"cache_size"`
+	Labels        []*intentLabel    `yaml:"labels" json:"labels"`
+	labelIndex    map[string]string // label -> profile
+}
+
+type intentLabel struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description"`
+	Profile     string `yaml:"profile" json:"profile"`
+}
+
+type classification struct {
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+}
+
+func (c *classifierConfig) validate(profiles map[string]*profileConfig) []error {
+	var errs []error
+	if p := profiles[c.Profile]; p == nil || p.modelID == "" {
+		errs = append(errs, fmt.Errorf("classifier.profile %q 未定义或未配置模型", c.Profile))
+	}
+	if c.MinConfidence < 0 || c.MinConfidence > 1 {
+		errs = append(errs, fmt.Errorf("classifier.min_confidence 必须在 [0,1] 之间，当前 %.2f", c.MinConfidence))
+	}
+	if c.TimeoutMS <= 0 {
+		c.TimeoutMS = 5000
+	}
+	if c.CacheSize <= 0 {
+		c.CacheSize = 1024
+	}
+	if len(c.Labels) == 0 {
+		errs = append(errs, fmt.Errorf("classifier.labels 不能为空"))
+	}
+	c.labelIndex = make(map[string]string, len(c.Labels))
+	for _, l := range c.Labels {
+		if l == nil || l.Name == "" {
+			errs = append(errs, fmt.Errorf("classifier.labels 中存在空标签"))
+			continue
+		}
+		if _, dup := c.labelIndex[l.Name]; dup {
+			errs = append(errs, fmt.Errorf("classifier 标签 %s 重复", l.Name))
+		}
+		if _, ok := profiles[l.Profile]; !ok {
+			errs = append(errs, fmt.Errorf("classifier 标签 %s 引用了未定义的 profile %q", l.Name, l.Profile))
+		}
+		c.labelIndex[l.Name] = l.Profile
+	}
+	return errs
+}
+
+type intentClassifier struct {
+	cfg   *classifierConfig
+	model *ratelimit.ChatModel
+	cache *classificationCache
+}
+
+// 分类模型单独实例化，以便用 JSON Schema 把输出约束在标签枚举内
+func newIntentClassifier(ctx context.Context, cfg *classifierConfig, pool *modelPool, modelID string) (*intentClassifier, error) {
+	labels := make([]string, 0, len(cfg.Labels))
+	for _, l := range cfg.Labels {
+		labels = append(labels, l.Name)
+	}
+	m, err := pool.newModel(ctx, modelID, &ark.ResponseFormat{
+		Type: arkmodel.ResponseFormatJSONSchema,
+		JSONSchema: &arkmodel.ResponseFormatJSONSchemaJSONSchemaParam{
+			Name:        "intent",
+			Description: "用户请求的意图分类",
+			Schema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"label":      map[string]any{"type": "string", "enum": labels},
+					"confidence": map[string]any{"type": "number", "minimum": 0, "maximum": 1},
+				},
+				"required":             []string{"label", "confidence"},
+				"additionalProperties": false,
+			},
+			Strict: true,
+		},
+	}, false)
+	if err != nil {
+		return nil, err
+	}
+	return &intentClassifier{cfg: cfg, model: m, cache: newClassificationCache(cfg.CacheSize)}, nil
+}
+
+func (c *intentClassifier) systemPrompt() string {
+	var builder strings.Builder
+	builder.WriteString("你是请求路由分类器。阅读用户请求，从下列标签中选出最合适的一个，并给出 0~1 的置信度。\n")
+	for _, l := range c.cfg.Labels {
+		builder.WriteString(fmt.Sprintf("- %s：%s\n", l.Name, l.Description))
+	}
+	builder.WriteString(`只输出 JSON，例如 {"label": "code", "confidence": 0.9}。`)
+	return builder.String()
+}
+
+func (c *intentClassifier) classify(ctx context.Context, query string) (classification, bool, error) {
+	key := strings.TrimSpace(query)
+	if cached, ok := c.cache.get(key); ok {
+		return cached, true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.cfg.TimeoutMS)*time.Millisecond)
+	defer cancel()
+	resp, err := c.model.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(c.systemPrompt()),
+		schema.UserMessage(query),
+	})
+	if err != nil {
+		return classification{}, false, err
+	}
+
+	var result classification
+	if err := json.Unmarshal([]byte(llm.TrimJSONFence(resp.Content)), &result); err != nil {
+		return classification{}, false, fmt.Errorf("解析分类结果失败: %w（原始输出：%s）", err, resp.Content)
+	}
+	if _, ok := c.cfg.labelIndex[result.Label]; !ok {
+		return classification{}, false, fmt.Errorf("分类器返回了未知标签 %q", result.Label)
+	}
+	c.cache.put(key, result)
+	return result, false, nil
+}
+
+// 有界缓存，超出容量时淘汰最早写入的条目
+type classificationCache struct {
+	mu    sync.Mutex
+	size  int
+	items map[string]classification
+	order []string
+}
+
+func newClassificationCache(size int) *classificationCache {
+	return &classificationCache{size: size, items: make(map[string]classification)}
+}
+
+func (c *classificationCache) get(key string) (classification, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.items[key]
+	return v, ok
+}
+
+func (c *classificationCache) put(key string, v classification) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[key]; !ok {
+		c.order = append(c.order, key)
+	}
+	c.items[key] = v
+	for len(c.order) > c.size {
+		delete(c.items, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// 分类路由：命中标签且置信度达标、目标模型可用时采用分类结果，否则回退到规则
+func (t *routeTable) classifyRoute(ctx context.Context, in routeInput) Decision {
+	fallback := func(reason string) Decision {
+		profile, rule := t.selectModel(in)
+		d := Decision{Profile: profile, Reason: fmt.Sprintf("%s，回退规则 %s", reason, rule)}
+		log.Printf("🏷️ 意图分类：%s -> %s", d.Reason, d.Profile)
+		return d
+	}
+
+	result, cached, err := t.classifier.classify(ctx, in.Query)
+	if err != nil {
+		return fallback(fmt.Sprintf("分类失败（%v）", err))
+	}
+	source := "模型"
+	if cached {
+		source = "缓存"
+	}
+	desc := fmt.Sprintf("label=%s confidence=%.2f 来源=%s", result.Label, result.Confidence, source)
+	if result.Confidence < t.cfg.Classifier.MinConfidence {
+		return fallback(desc + " 置信度不足")
+	}
+	profile := t.cfg.Classifier.labelIndex[result.Label]
+	if t.models[profile] == nil {
+		return fallback(desc + fmt.Sprintf(" 目标 %s 未配置模型", profile))
+	}
+	log.Printf("🏷️ 意图分类：%s -> %s", desc, profile)
+	return Decision{Profile: profile, Reason: "classifier:" + result.Label}
+}
@@ -0,0 +1,282 @@
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"agent-demo/llm"
+	"agent-demo/ratelimit"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 路由配置文件：命名的模型档案 + 按优先级匹配的规则，支持 YAML 与 JSON
+type routerConfig struct {
+	// 路由策略：rules（默认，仅关键词规则）、classifier（模型意图分类）、semantic（示例相似度），后两者失败时回退规则；
+	// cascade 则不预先选择，由便宜模型先答、置信度不足时逐级升级
+	Strategy       string                    `yaml:"strategy" json:"strategy"`
+	DefaultProfile string                    `yaml:"default_profile" json:"default_profile"`
+	Profiles       map[string]*profileConfig `yaml:"profiles" json:"profiles"`
+	Rules          []*ruleConfig             `yaml:"rules" json:"rules"`
+	Classifier     *classifierConfig         `yaml:"classifier" json:"classifier"`
+	Semantic       *semanticConfig           `yaml:"semantic" json:"semantic"`
+	Breaker        *breakerConfig            `yaml:"breaker" json:"breaker"`
+	Hedging        *hedgeConfig              `yaml:"hedging" json:"hedging"`
+	Cascade        *cascadeConfig            `yaml:"cascade" json:"cascade"`
+	Experiment     *experimentConfig         `yaml:"experiment" json:"experiment"`
+	Shadow         *shadowConfig             `yaml:"shadow" json:"shadow"`
+	Health         *healthConfig             `yaml:"health" json:"health"`
+
+	provider string // 模型服务，mock 时未配置模型 ID 的 profile 也能使用
+}
+
+const (
+	strategyRules      = "rules"
+	strategyClassifier = "classifier"
+	strategySemantic   = "semantic"
+	strategyCascade    = "cascade"
+)
+
+type profileConfig struct {
+	// 模型 ID，直接写死或从环境变量中取第一个非空值；使用 mock 模型服务时未配置的默认为 mock-<profile>
+	Model    string   `yaml:"model" json:"model"`
+	ModelEnv []string `yaml:"model_env" json:"model_env"`
+	// 语义路由使用的示例语句
+	Examples []string `yaml:"examples" json:"examples"`
+	// 调用失败（超时、5xx、限流）时依次尝试的 profile
+	Fallbacks []string `yaml:"fallbacks" json:"fallbacks"`
+	// 单次调用超时，0 表示不限制
+	TimeoutMS int `yaml:"timeout_ms" json:"timeout_ms"`
+	// 每千 token 价格，级联策略用来统计费用
+	Price *priceConfig `yaml:"price" json:"price"`
+	// 模型能力，路由时据此排除处理不了的请求；不填表示不受限
+	ContextWindow   int   `yaml:"context_window" json:"context_window"`
+	MaxOutputTokens int   `yaml:"max_output_tokens" json:"max_output_tokens"`
+	Vision          *bool `yaml:"vision" json:"vision"`
+	Tools           *bool `yaml:"tools" json:"tools"`
+	// 按模型 ID 限流（RPM/TPM），多个 profile 共用同一模型时共享额度
+	RateLimit *ratelimit.Config `yaml:"rate_limit" json:"rate_limit"`
+	// 选中该 profile 时默认使用的生成参数，调用方传入的参数优先
+	Params *paramsConfig `yaml:"params" json:"params"`
+
+	modelID string
+}
+
+// 一条规则内的各项条件是“且”的关系；关键词、正则各自内部是“或”的关系
+type ruleConfig struct {
+	Name      string   `yaml:"name" json:"name"`
+	Priority  int      `yaml:"priority" json:"priority"`
+	Profile   string   `yaml:"profile" json:"profile"`
+	Keywords  []string `yaml:"keywords" json:"keywords"`
+	Regexes   []string `yaml:"regexes" json:"regexes"`
+	MinLength int      `yaml:"min_length" json:"min_length"` // 按字符（rune）计，0 表示不限
+	MaxLength int      `yaml:"max_length" json:"max_length"`
+	HasImages *bool    `yaml:"has_images" json:"has_images"`
+	HasTools  *bool    `yaml:"has_tools" json:"has_tools"`
+
+	compiled []*regexp.Regexp
+}
+
+func loadRouterConfig(path, provider string) (*routerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &routerConfig{provider: provider}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	} else {
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析路由配置 %s 失败: %w", path, err)
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("路由配置 %s 校验失败: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// 校验并预处理：解析模型 ID、编译正则、按优先级排序规则
+func (c *routerConfig) validate() error {
+	var errs []error
+
+	if len(c.Profiles) == 0 {
+		errs = append(errs, errors.New("至少需要配置一个 profile"))
+	}
+	for name, p := range c.Profiles {
+		if p == nil {
+			errs = append(errs, fmt.Errorf("profile %s 为空", name))
+			continue
+		}
+		p.modelID = p.Model
+		for _, env := range p.ModelEnv {
+			if p.modelID != "" {
+				break
+			}
+			p.modelID = strings.TrimSpace(os.Getenv(env))
+		}
+		if p.modelID == "" && c.provider == llm.ProviderMock {
+			p.modelID = "mock-" + name
+		}
+		if p.TimeoutMS < 0 {
+			errs = append(errs, fmt.Errorf("profile %s 的 timeout_ms 不能为负数", name))
+		}
+		for _, fb := range p.Fallbacks {
+			if fb == name {
+				errs = append(errs, fmt.Errorf("profile %s 的回退链不能包含自身", name))
+			} else if _, ok := c.Profiles[fb]; !ok {
+				errs = append(errs, fmt.Errorf("profile %s 的回退链引用了未定义的 profile %q", name, fb))
+			}
+		}
+		if p.ContextWindow < 0 || p.MaxOutputTokens < 0 {
+			errs = append(errs, fmt.Errorf("profile %s 的 context_window / max_output_tokens 不能为负数", name))
+		}
+		if p.ContextWindow > 0 && p.MaxOutputTokens > p.ContextWindow {
+			errs = append(errs, fmt.Errorf("profile %s 的 max_output_tokens(%d) 大于 context_window(%d)", name, p.MaxOutputTokens, p.ContextWindow))
+		}
+		if p.RateLimit != nil && (p.RateLimit.RPM < 0 || p.RateLimit.TPM < 0 || p.RateLimit.MaxWaitMS < 0) {
+			errs = append(errs, fmt.Errorf("profile %s 的 rate_limit 不能为负数", name))
+		}
+		if p.Price != nil && (p.Price.Input < 0 || p.Price.Output < 0) {
+			errs = append(errs, fmt.Errorf("profile %s 的 price 不能为负数", name))
+		}
+		if p.Params != nil {
+			errs = append(errs, p.Params.validate(name, p)...)
+		}
+	}
+
+	if c.Breaker == nil {
+		c.Breaker = &breakerConfig{}
+	}
+	c.Breaker.applyDefaults()
+	if c.Health == nil {
+		c.Health = &healthConfig{}
+	}
+	errs = append(errs, c.Health.validate(c.Profiles)...)
+	if c.Hedging != nil {
+		errs = append(errs, c.Hedging.validate(c.Profiles)...)
+	}
+	if c.Experiment != nil {
+		errs = append(errs, c.Experiment.validate(c.Profiles)...)
+	}
+	if c.Shadow != nil {
+		errs = append(errs, c.Shadow.validate(c.Profiles)...)
+	}
+
+	if c.DefaultProfile == "" {
+		errs = append(errs, errors.New("缺少 default_profile"))
+	} else if p := c.Profiles[c.DefaultProfile]; p == nil {
+		errs = append(errs, fmt.Errorf("default_profile %s 未定义", c.DefaultProfile))
+	} else if p.modelID == "" {
+		errs = append(errs, fmt.Errorf("默认 profile %s 未配置模型 ID（model 或 model_env）", c.DefaultProfile))
+	}
+
+	switch c.Strategy {
+	case "":
+		c.Strategy = strategyRules
+	case strategyRules:
+	case strategyClassifier:
+		if c.Classifier == nil {
+			errs = append(errs, errors.New("strategy 为 classifier 时必须配置 classifier"))
+		} else {
+			errs = append(errs, c.Classifier.validate(c.Profiles)...)
+		}
+	case strategySemantic:
+		if c.Semantic == nil {
+			c.Semantic = &semanticConfig{Threshold: 0.3}
+		}
+		errs = append(errs, c.Semantic.validate(c.Profiles)...)
+	case strategyCascade:
+		if c.Cascade == nil {
+			errs = append(errs, errors.New("strategy 为 cascade 时必须配置 cascade"))
+		} else {
+			errs = append(errs, c.Cascade.validate(c.Profiles)...)
+		}
+	default:
+		errs = append(errs, fmt.Errorf("未知的路由策略 %q", c.Strategy))
+	}
+
+	for i, r := range c.Rules {
+		if r == nil {
+			errs = append(errs, fmt.Errorf("第 %d 条规则为空", i+1))
+			continue
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule#%d", i+1)
+		}
+		if _, ok := c.Profiles[r.Profile]; !ok {
+			errs = append(errs, fmt.Errorf("规则 %s 引用了未定义的 profile %q", r.Name, r.Profile))
+		}
+		if r.MinLength < 0 || r.MaxLength < 0 {
+			errs = append(errs, fmt.Errorf("规则 %s 的长度范围不能为负数", r.Name))
+		}
+		if r.MaxLength > 0 && r.MinLength > r.MaxLength {
+			errs = append(errs, fmt.Errorf("规则 %s 的 min_length(%d) 大于 max_length(%d)", r.Name, r.MinLength, r.MaxLength))
+		}
+		r.compiled = r.compiled[:0]
+		for _, expr := range r.Regexes {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("规则 %s 的正则 %q 无效: %w", r.Name, expr, err))
+				continue
+			}
+			r.compiled = append(r.compiled, re)
+		}
+		if len(r.Keywords) == 0 && len(r.Regexes) == 0 && r.MinLength == 0 && r.MaxLength == 0 && r.HasImages == nil && r.HasTools == nil {
+			errs = append(errs, fmt.Errorf("规则 %s 没有任何匹配条件", r.Name))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// 优先级高的先匹配，同优先级保持文件中的顺序
+	sort.SliceStable(c.Rules, func(i, j int) bool {
+		return c.Rules[i].Priority > c.Rules[j].Priority
+	})
+	return nil
+}
+
+// 轮询配置文件的修改时间与大小，发生变化时回调；回调方负责重新加载与校验
+func watchConfig(ctx context.Context, path string, interval time.Duration, onChange func()) {
+	stat := func() (time.Time, int64) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, -1
+		}
+		return fi.ModTime(), fi.Size()
+	}
+
+	lastMod, lastSize := stat()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mod, size := stat()
+			if size < 0 || (mod.Equal(lastMod) && size == lastSize) {
+				continue
+			}
+			lastMod, lastSize = mod, size
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+// A/B 实验：按用户或会话 ID 把流量按权重分到不同 variant，每个 variant 固定使用一个 profile。
+// 分配采用加权 rendezvous 哈希，同一 ID 始终落在同一 variant，调整权重或增删 variant 时只迁移必要的用户
+type experimentConfig struct {
+	Name     string           `yaml:"name" json:"name"`
+	Variants []*variantConfig `yaml:"variants" json:"variants"`
+}
+
+type variantConfig struct {
+	Name    string  `yaml:"name" json:"name"`
+	Profile string  `yaml:"profile" json:"profile"`
+	Weight  float64 `yaml:"weight" json:"weight"`
+}
+
+func (c *experimentConfig) validate(profiles map[string]*profileConfig) []error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, errors.New("experiment.name 不能为空"))
+	}
+	if len(c.Variants) < 2 {
+		errs = append(errs, errors.New("experiment.variants 至少需要两个"))
+	}
+	seen := make(map[string]bool)
+	for i, v := range c.Variants {
+		if v == nil || v.Name == "" {
+			errs = append(errs, fmt.Errorf("experiment 第 %d 个 variant 缺少 name", i+1))
+			continue
+		}
+		if seen[v.Name] {
+			errs = append(errs, fmt.Errorf("experiment variant %s 重复", v.Name))
+		}
+		seen[v.Name] = true
+		if p := profiles[v.Profile]; p == nil || p.modelID == "" {
+			errs = append(errs, fmt.Errorf("experiment variant %s 的 profile %q 未定义或未配置模型", v.Name, v.Profile))
+		}
+		if v.Weight <= 0 {
+			errs = append(errs, fmt.Errorf("experiment variant %s 的 weight 必须大于 0", v.Name))
+		}
+	}
+	return errs
+}
+
+// 对每个 variant 计算 -weight/ln(h)，取最大者；h 为 (实验, variant, 主体) 的哈希映射到 (0,1)
+func (c *experimentConfig) assign(subject string) *variantConfig {
+	var (
+		best  *variantConfig
+		score = math.Inf(-1)
+	)
+	for _, v := range c.Variants {
+		h := fnv.New64a()
+		h.Write([]byte(c.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(v.Name))
+		h.Write([]byte{0})
+		h.Write([]byte(subject))
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		if s := -v.Weight / math.Log(u); s > score {
+			best, score = v, s
+		}
+	}
+	return best
+}
+
+type subjectKey struct{}
+
+// WithSubject 在 ctx 中标记用户或会话 ID；只有带 ID 的请求参与 A/B 实验
+func WithSubject(ctx context.Context, subject string) context.Context {
+	return context.WithValue(ctx, subjectKey{}, subject)
+}
+
+func subjectFrom(ctx context.Context) string {
+	s, _ := ctx.Value(subjectKey{}).(string)
+	return s
+}
+
+// 按实验分配覆盖路由结果；未配置实验或请求不带 ID 时原样返回
+func (t *routeTable) applyExperiment(ctx context.Context, decision Decision) (Decision, string) {
+	exp := t.cfg.Experiment
+	subject := subjectFrom(ctx)
+	if exp == nil || subject == "" {
+		return decision, ""
+	}
+	v := exp.assign(subject)
+	return Decision{Profile: v.Profile, Reason: fmt.Sprintf("ab:%s/%s（原路由 %s）", exp.Name, v.Name, decision.Profile)}, v.Name
+}
+
+// 各 variant 的调用统计，按“实验/variant”累计，跨配置热加载保留
+type experimentStats struct {
+	mu       sync.Mutex
+	variants map[variantKey]*variantCounter
+	order    []variantKey
+}
+
+type variantKey struct{ experiment, variant string }
+
+type variantCounter struct {
+	requests, errors   int64
+	latency            time.Duration
+	positive, negative int64
+}
+
+type VariantStats struct {
+	Experiment string
+	Variant    string
+	Requests   int64
+	Errors     int64
+	AvgLatency time.Duration
+	Positive   int64
+	Negative   int64
+}
+
+func (s VariantStats) String() string {
+	errRate, likeRate := 0.0, 0.0
+	if s.Requests > 0 {
+		errRate = float64(s.Errors) * 100 / float64(s.Requests)
+	}
+	if n := s.Positive + s.Negative; n > 0 {
+		likeRate = float64(s.Positive) * 100 / float64(n)
+	}
+	return fmt.Sprintf("%s/%s：请求 %d，失败 %d（%.1f%%），平均耗时 %s，反馈 👍%d 👎%d（好评率 %.1f%%）",
+		s.Experiment, s.Variant, s.Requests, s.Errors, errRate, s.AvgLatency.Round(time.Millisecond), s.Positive, s.Negative, likeRate)
+}
+
+func (s *experimentStats) counter(experiment, variant string) *variantCounter {
+	key := variantKey{experiment, variant}
+	c, ok := s.variants[key]
+	if !ok {
+		if s.variants == nil {
+			s.variants = make(map[variantKey]*variantCounter)
+		}
+		c = &variantCounter{}
+		s.variants[key] = c
+		s.order = append(s.order, key)
+	}
+	return c
+}
+
+func (s *experimentStats) observe(experiment, variant string, latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counter(experiment, variant)
+	c.requests++
+	c.latency += latency
+	if err != nil {
+		c.errors++
+	}
+}
+
+func (s *experimentStats) feedback(experiment, variant string, positive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.counter(experiment, variant)
+	if positive {
+		c.positive++
+	} else {
+		c.negative++
+	}
+}
+
+func (s *experimentStats) snapshot() []VariantStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]VariantStats, 0, len(s.order))
+	for _, key := range s.order {
+		c := s.variants[key]
+		vs := VariantStats{
+			Experiment: key.experiment,
+			Variant:    key.variant,
+			Requests:   c.requests,
+			Errors:     c.errors,
+			Positive:   c.positive,
+			Negative:   c.negative,
+		}
+		if c.requests > 0 {
+			vs.AvgLatency = c.latency / time.Duration(c.requests)
+		}
+		out = append(out, vs)
+	}
+	return out
+}
@@ -0,0 +1,299 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"agent-demo/ratelimit"
+
+	goopenai "github.com/meguminnnnnnnnn/go-openai"
+	arkmodel "github.com/volcengine/volcengine-go-sdk/service/arkruntime/model"
+)
+
+// 熔断参数：连续失败 FailureThreshold 次后熔断 OpenSeconds 秒，之后半开放行少量探测请求
+type breakerConfig struct {
+	FailureThreshold int `yaml:"failure_threshold" json:"failure_threshold"`
+	OpenSeconds      int `yaml:"open_seconds" json:"open_seconds"`
+	HalfOpenRequests int `yaml:"half_open_requests" json:"half_open_requests"`
+}
+
+func (c *breakerConfig) applyDefaults() {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 3
+	}
+	if c.OpenSeconds <= 0 {
+		c.OpenSeconds = 30
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// 每个模型 ID 一个熔断器，跨配置热加载保留状态
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openUntil time.Time
+	probes    int // 半开状态下放行中的探测请求数
+}
+
+func (b *circuitBreaker) allow(cfg *breakerConfig) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		if time.Now().Before(b.openUntil) {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probes = 0
+		fallthrough
+	case breakerHalfOpen:
+		if b.probes >= cfg.HalfOpenRequests {
+			return false
+		}
+		b.probes++
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) record(cfg *breakerConfig, success bool) breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if success {
+		b.state, b.failures, b.probes = breakerClosed, 0, 0
+		return b.state
+	}
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= cfg.FailureThreshold {
+		b.state = breakerOpen
+		b.openUntil = time.Now().Add(time.Duration(cfg.OpenSeconds) * time.Second)
+		b.probes = 0
+	}
+	return b.state
+}
+
+// 当前状态；熔断时间已过但还没有请求进来时视为半开
+func (b *circuitBreaker) current() breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerOpen && !time.Now().Before(b.openUntil) {
+		return breakerHalfOpen
+	}
+	return b.state
+}
+
+// 调用被取消时归还半开探测名额，不计成功也不计失败
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (p *modelPool) breaker(modelID string) *circuitBreaker {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b, ok := p.breakers[modelID]
+	if !ok {
+		b = &circuitBreaker{}
+		p.breakers[modelID] = b
+	}
+	return b
+}
+
+// CallRecord 记录一次调用：路由选中谁、实际由谁回答、每次尝试的结果
+type CallRecord struct {
+	Requested string
+	Answered  string
+	Attempts  []Attempt
+	// 参与 A/B 实验时为“实验/variant”，与响应 Extra 中的 ExtraKeyVariant 相同
+	Variant string
+
+	// 流式调用成功后，应答方的 ctx 交由流的读取方在读完或关闭时释放
+	release context.CancelFunc
+}
+
+type Attempt struct {
+	Profile string
+	ModelID string
+	Latency time.Duration
+	Err     error
+	Skipped string // 非空表示未实际调用（如熔断中）
+}
+
+func (r *CallRecord) String() string {
+	parts := make([]string, 0, len(r.Attempts))
+	for _, a := range r.Attempts {
+		switch {
+		case a.Skipped != "":
+			parts = append(parts, fmt.Sprintf("%s(跳过:%s)", a.Profile, a.Skipped))
+		case a.Err != nil:
+			parts = append(parts, fmt.Sprintf("%s(失败 %s)", a.Profile, a.Latency.Round(time.Millisecond)))
+		default:
+			parts = append(parts, fmt.Sprintf("%s(成功 %s)", a.Profile, a.Latency.Round(time.Millisecond)))
+		}
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// 路由选中的 profile 在前，依次接上它声明的回退链；去重并跳过未配置模型或能力不足的 profile
+func (t *routeTable) fallbackChain(profile string, need *requirements) []string {
+	seen := make(map[string]bool)
+	var chain []string
+	add := func(name string) {
+		if seen[name] || !t.capable(name, need) {
+			return
+		}
+		seen[name] = true
+		chain = append(chain, name)
+	}
+	add(profile)
+	if p := t.cfg.Profiles[profile]; p != nil {
+		for _, fb := range p.Fallbacks {
+			add(fb)
+		}
+	}
+	return chain
+}
+
+// 沿回退链调用模型：超时、5xx、限流等可重试错误切换到下一个模型，其它错误直接返回。
+// call 负责实际调用（Generate 或建立 Stream），返回 nil 即视为该模型已应答。
+// 单次超时只限制拿到应答（流式调用为建立连接）之前的等待；流式调用成功后 ctx 不再受超时约束，
+// 由 record.release 在流读完或被关闭时释放。discard 非 nil 表示流式调用，
+// 超时与建立连接同时发生时用它关闭已经建立、但 ctx 已被取消的流
+func (t *routeTable) callChain(ctx context.Context, profile string, need *requirements, discard func(), call func(ctx context.Context, name string, m *ratelimit.ChatModel) error) (*CallRecord, error) {
+	stream := discard != nil
+	record := &CallRecord{Requested: profile}
+	chain := t.fallbackChain(profile, need)
+	if len(chain) == 0 {
+		return record, fmt.Errorf("profile %s 未配置可用模型", profile)
+	}
+
+	var lastErr error
+	for _, name := range chain {
+		p := t.cfg.Profiles[name]
+		b := t.pool.breaker(p.modelID)
+		attempt := Attempt{Profile: name, ModelID: p.modelID}
+		if !b.allow(t.cfg.Breaker) {
+			attempt.Skipped = "熔断中"
+			record.Attempts = append(record.Attempts, attempt)
+			continue
+		}
+
+		timeout := time.Duration(p.TimeoutMS) * time.Millisecond
+		callCtx, cancel := context.WithCancelCause(ctx)
+		var timer *time.Timer
+		if timeout > 0 {
+			timer = time.AfterFunc(timeout, func() { cancel(context.DeadlineExceeded) })
+		}
+		start := time.Now()
+		err := call(callCtx, name, t.models[name])
+		if timer != nil && !timer.Stop() {
+			switch {
+			case err == nil && !stream:
+				// 计时器与应答几乎同时触发，完整的应答已经拿到，照常使用
+			case err == nil:
+				// 流已建立但 ctx 已被取消，读不出内容，关掉它以释放上游连接
+				discard()
+				err = fmt.Errorf("%s 内未应答: %w", timeout, context.DeadlineExceeded)
+			default:
+				// 超时先于应答触发：底层返回的是 context.Canceled，统一改成超时以便切换到下一个模型
+				err = fmt.Errorf("%s 内未应答: %w", timeout, context.DeadlineExceeded)
+			}
+		}
+		if stream && err == nil {
+			record.release = func() { cancel(nil) }
+		} else {
+			cancel(nil)
+		}
+		attempt.Latency = time.Since(start)
+		attempt.Err = err
+		record.Attempts = append(record.Attempts, attempt)
+		if ctx.Err() == nil {
+			t.observe(p.modelID, attempt.Latency, err)
+		}
+
+		if err == nil {
+			b.record(t.cfg.Breaker, true)
+			record.Answered = name
+			return record, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			// 调用方已取消，不再尝试其它模型
+			b.release()
+			return record, err
+		}
+		if !isRetryable(err) {
+			// 请求本身有问题（如 400），模型是健康的
+			b.record(t.cfg.Breaker, true)
+			return record, err
+		}
+		if state := b.record(t.cfg.Breaker, false); state == breakerOpen {
+			log.Printf("⚡ 模型 %s（%s）熔断 %d 秒", name, p.modelID, t.cfg.Breaker.OpenSeconds)
+		}
+		log.Printf("↪️ 模型 %s 调用失败，尝试下一个: %v", name, err)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("回退链上的模型均处于熔断状态")
+	}
+	return record, fmt.Errorf("回退链 %s 全部失败: %w", strings.Join(chain, " -> "), lastErr)
+}
+
+// 超时、网络错误、429 与 5xx 视为模型侧故障，可以切换模型重试
+func isRetryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	status := 0
+	var apiErr *arkmodel.APIError
+	var reqErr *arkmodel.RequestError
+	var oaiAPIErr *goopenai.APIError
+	var oaiReqErr *goopenai.RequestError
+	switch {
+	case errors.As(err, &apiErr):
+		status = apiErr.HTTPStatusCode
+	case errors.As(err, &reqErr):
+		status = reqErr.HTTPStatusCode
+	case errors.As(err, &oaiAPIErr):
+		status = oaiAPIErr.HTTPStatusCode
+	case errors.As(err, &oaiReqErr):
+		status = oaiReqErr.HTTPStatusCode
+	}
+	return status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= http.StatusInternalServerError
+}
@@ -0,0 +1,165 @@
+package router
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"agent-demo/llm"
+	"agent-demo/ratelimit"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 写出一份只有 default profile 的路由配置与 mock 脚本，返回对应的 Router
+func newMockRouter(t *testing.T, routes, script string) *Router {
+	t.Helper()
+	dir := t.TempDir()
+	routesPath := filepath.Join(dir, "routes.yaml")
+	scriptPath := filepath.Join(dir, "mock.yaml")
+	if err := os.WriteFile(routesPath, []byte(routes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(scriptPath, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := New(context.Background(), routesPath, llm.Config{Provider: llm.ProviderMock, Model: "mock", MockScript: scriptPath})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// 流建立后读取时间超过 timeout_ms 也不能被截断
+func TestStreamOutlivesAttemptTimeout(t *testing.T) {
+	r := newMockRouter(t, `
+strategy: rules
+default_profile: default
+profiles:
+  default:
+    timeout_ms: 100
+`, `
+chunk_runes: 2
+chunk_delay_ms: 60
+rules:
+  - content: 这是一段需要较长时间才能输出完的回答
+`)
+	sr, err := r.Stream(context.Background(), []*schema.Message{schema.UserMessage("你好")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sr.Close()
+	var b strings.Builder
+	for {
+		chunk, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("读取到一半出错: %v（已读到 %q）", err, b.String())
+		}
+		b.WriteString(chunk.Content)
+	}
+	if got, want := b.String(), "这是一段需要较长时间才能输出完的回答"; got != want {
+		t.Fatalf("流内容 = %q，期望 %q", got, want)
+	}
+}
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	cfg := &breakerConfig{FailureThreshold: 2, OpenSeconds: 60, HalfOpenRequests: 1}
+	b := &circuitBreaker{}
+	expire := func() {
+		b.mu.Lock()
+		b.openUntil = time.Now().Add(-time.Millisecond)
+		b.mu.Unlock()
+	}
+
+	// closed：未达到阈值前保持放行
+	if !b.allow(cfg) || b.record(cfg, false) != breakerClosed {
+		t.Fatal("第一次失败后应仍为 closed")
+	}
+	// 连续失败达到阈值后熔断，熔断期内拒绝请求
+	if !b.allow(cfg) || b.record(cfg, false) != breakerOpen {
+		t.Fatal("连续失败达到阈值后应为 open")
+	}
+	if b.allow(cfg) || b.current() != breakerOpen {
+		t.Fatal("open 期间不应放行")
+	}
+
+	// 熔断到期后半开，只放行 half_open_requests 个探测
+	expire()
+	if b.current() != breakerHalfOpen {
+		t.Fatalf("熔断到期后状态 = %s，期望 half-open", b.current())
+	}
+	if !b.allow(cfg) || b.allow(cfg) {
+		t.Fatal("half-open 应只放行 1 个探测请求")
+	}
+	// 探测被取消时归还名额
+	b.release()
+	if !b.allow(cfg) {
+		t.Fatal("归还名额后应能再次放行探测")
+	}
+	// 探测失败立即重新熔断，不等累计到阈值
+	if b.record(cfg, false) != breakerOpen || b.allow(cfg) {
+		t.Fatal("half-open 探测失败后应重新 open")
+	}
+
+	// 再次到期后探测成功，恢复为 closed 并清零失败计数
+	expire()
+	if !b.allow(cfg) || b.record(cfg, true) != breakerClosed {
+		t.Fatal("half-open 探测成功后应为 closed")
+	}
+	if !b.allow(cfg) || b.record(cfg, false) != breakerClosed {
+		t.Fatal("恢复后失败计数应从零开始")
+	}
+}
+
+// 计时器在调用成功返回时已经触发：完整应答照常使用，已建立的流要关掉并切换到下一个模型
+func TestCallChainTimerFiresAfterSuccess(t *testing.T) {
+	r := newMockRouter(t, `
+strategy: rules
+default_profile: primary
+profiles:
+  primary:
+    model: primary-model
+    timeout_ms: 10
+    fallbacks: [backup]
+  backup:
+    model: backup-model
+`, `
+rules:
+  - content: ok
+`)
+	tbl := r.table.Load()
+	// 不理会 ctx，模拟底层恰好在超时那一刻返回成功
+	late := func(ctx context.Context, name string, _ *ratelimit.ChatModel) error {
+		if name == "primary" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		return nil
+	}
+
+	record, err := tbl.callChain(context.Background(), "primary", nil, nil, late)
+	if err != nil || record.Answered != "primary" {
+		t.Fatalf("非流式调用已拿到应答时应直接使用，实际由 %q 应答，错误 %v", record.Answered, err)
+	}
+
+	var discarded []string
+	var current string
+	record, err = tbl.callChain(context.Background(), "primary", nil, func() { discarded = append(discarded, current) },
+		func(ctx context.Context, name string, m *ratelimit.ChatModel) error {
+			current = name
+			return late(ctx, name, m)
+		})
+	if err != nil || record.Answered != "backup" {
+		t.Fatalf("流式调用超时后应由 backup 应答，实际由 %q 应答，错误 %v", record.Answered, err)
+	}
+	defer record.release()
+	if strings.Join(discarded, ",") != "primary" {
+		t.Errorf("应只关闭 primary 已建立的流，实际关闭了 %v", discarded)
+	}
+}
@@ -0,0 +1,349 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 健康检查与自适应权重：按模型 ID 维护延迟与错误率的 EWMA，空闲模型定期发送探测请求；
+// groups 中的 profile 视为等价，路由到其中任意一个时按健康度加权挑选
+type healthConfig struct {
+	Alpha          float64             `yaml:"alpha" json:"alpha"` // EWMA 平滑系数，越大越看重最近的样本
+	ProbeInterval  int                 `yaml:"probe_interval_s" json:"probe_interval_s"`
+	IdleAfter      int                 `yaml:"idle_after_s" json:"idle_after_s"` // 超过该时长没有流量的模型才会被探测
+	ProbeTimeoutMS int                 `yaml:"probe_timeout_ms" json:"probe_timeout_ms"`
+	Groups         map[string][]string `yaml:"groups" json:"groups"`
+
+	groupOf map[string]string // profile -> 组名
+}
+
+// 组内每个模型至少分到的流量比例，保证恢复后的模型还能被观测到
+const minGroupShare = 0.05
+
+func (c *healthConfig) validate(profiles map[string]*profileConfig) []error {
+	var errs []error
+	if c.Alpha == 0 {
+		c.Alpha = 0.2
+	}
+	if c.Alpha < 0 || c.Alpha > 1 {
+		errs = append(errs, fmt.Errorf("health.alpha 必须在 (0,1] 之间，当前 %.2f", c.Alpha))
+	}
+	if c.ProbeInterval <= 0 {
+		c.ProbeInterval = 30
+	}
+	if c.IdleAfter <= 0 {
+		c.IdleAfter = 60
+	}
+	if c.ProbeTimeoutMS <= 0 {
+		c.ProbeTimeoutMS = 5000
+	}
+	c.groupOf = make(map[string]string)
+	for group, members := range c.Groups {
+		if len(members) < 2 {
+			errs = append(errs, fmt.Errorf("health.groups.%s 至少需要两个 profile", group))
+		}
+		for _, name := range members {
+			if _, ok := profiles[name]; !ok {
+				errs = append(errs, fmt.Errorf("health.groups.%s 引用了未定义的 profile %q", group, name))
+				continue
+			}
+			if other, dup := c.groupOf[name]; dup {
+				errs = append(errs, fmt.Errorf("profile %s 同时属于 health.groups.%s 与 %s", name, other, group))
+			}
+			c.groupOf[name] = group
+		}
+	}
+	return errs
+}
+
+// 单个模型 ID 的健康状态，跨配置热加载保留
+type modelHealth struct {
+	mu        sync.Mutex
+	latency   float64 // 成功调用耗时的 EWMA，毫秒
+	errRate   float64
+	samples   int64
+	lastUsed  time.Time
+	lastProbe time.Time
+	lastErr   string
+}
+
+func (h *modelHealth) observe(alpha float64, latency time.Duration, err error, probe bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if probe {
+		h.lastProbe = now
+	} else {
+		h.lastUsed = now
+	}
+	failed := 0.0
+	if err != nil {
+		failed = 1
+		h.lastErr = err.Error()
+	}
+	ms := float64(latency) / float64(time.Millisecond)
+	if h.samples == 0 {
+		h.errRate = failed
+		if err == nil {
+			h.latency = ms
+		}
+	} else {
+		h.errRate = alpha*failed + (1-alpha)*h.errRate
+		if err == nil {
+			if h.latency == 0 {
+				h.latency = ms
+			} else {
+				h.latency = alpha*ms + (1-alpha)*h.latency
+			}
+		}
+	}
+	h.samples++
+}
+
+// 调用方主动取消的请求说明不了模型的好坏，不计入
+func (t *routeTable) observe(modelID string, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	t.pool.health(modelID).observe(t.cfg.Health.Alpha, latency, err, false)
+}
+
+func (p *modelPool) health(modelID string) *modelHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h, ok := p.healths[modelID]
+	if !ok {
+		h = &modelHealth{}
+		p.healths[modelID] = h
+	}
+	return h
+}
+
+// 健康分：成功率除以延迟，没有样本时返回 false
+func (h *modelHealth) score() (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.samples == 0 {
+		return 0, false
+	}
+	latency := math.Max(h.latency, 1)
+	if h.latency == 0 {
+		// 只有失败样本，按很慢处理
+		latency = 60000
+	}
+	return (1 - h.errRate) / latency, true
+}
+
+// 计算组内各 profile 的权重；need 不为 nil 时排除能力不足的成员
+func (t *routeTable) groupWeights(group string, need *requirements) map[string]float64 {
+	var members []string
+	for _, name := range t.cfg.Health.Groups[group] {
+		if t.capable(name, need) && t.pool.breaker(t.cfg.Profiles[name].modelID).current() != breakerOpen {
+			members = append(members, name)
+		}
+	}
+	if len(members) == 0 {
+		return nil
+	}
+
+	scores := make(map[string]float64, len(members))
+	known, sum := 0, 0.0
+	for _, name := range members {
+		if s, ok := t.pool.health(t.cfg.Profiles[name].modelID).score(); ok {
+			scores[name] = s
+			known++
+			sum += s
+		}
+	}
+	// 没有样本的成员按已知成员的平均分对待，避免新模型永远分不到流量
+	avg := 1.0
+	if known > 0 && sum > 0 {
+		avg = sum / float64(known)
+	}
+	total := 0.0
+	for _, name := range members {
+		if _, ok := scores[name]; !ok {
+			scores[name] = avg
+		}
+		total += scores[name]
+	}
+
+	weights := make(map[string]float64, len(members))
+	floor := math.Min(minGroupShare, 1/float64(len(members)))
+	norm := 0.0
+	for _, name := range members {
+		w := 1 / float64(len(members))
+		if total > 0 {
+			w = scores[name] / total
+		}
+		weights[name] = math.Max(w, floor)
+		norm += weights[name]
+	}
+	for name := range weights {
+		weights[name] /= norm
+	}
+	return weights
+}
+
+// 路由结果落在等价组内时按权重重新挑选组内成员
+func (t *routeTable) balance(decision Decision, need *requirements) Decision {
+	group, ok := t.cfg.Health.groupOf[decision.Profile]
+	if !ok {
+		return decision
+	}
+	weights := t.groupWeights(group, need)
+	if len(weights) < 2 {
+		return decision
+	}
+	names := make([]string, 0, len(weights))
+	for name := range weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pick, x := names[len(names)-1], rand.Float64()
+	for _, name := range names {
+		if x < weights[name] {
+			pick = name
+			break
+		}
+		x -= weights[name]
+	}
+	if pick == decision.Profile {
+		return decision
+	}
+	return Decision{Profile: pick, Reason: fmt.Sprintf("%s；组 %s 按健康度加权改用 %s（%.0f%%）", decision.Reason, group, pick, weights[pick]*100)}
+}
+
+// 对空闲模型发送最小的探测请求，刷新它们的健康状态
+func (t *routeTable) probeIdle(ctx context.Context) {
+	cfg := t.cfg.Health
+	idle := time.Duration(cfg.IdleAfter) * time.Second
+	seen := make(map[string]bool)
+	var wg sync.WaitGroup
+	for name, m := range t.models {
+		id := t.cfg.Profiles[name].modelID
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		h := t.pool.health(id)
+		h.mu.Lock()
+		due := time.Since(h.lastUsed) > idle && time.Since(h.lastProbe) > idle
+		h.mu.Unlock()
+		if !due {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, time.Duration(cfg.ProbeTimeoutMS)*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			_, err := m.Generate(probeCtx, []*schema.Message{schema.UserMessage("ping")}, model.WithMaxTokens(1))
+			if ctx.Err() != nil {
+				return
+			}
+			h.observe(cfg.Alpha, time.Since(start), err, true)
+			if err != nil {
+				log.Printf("🩺 探测 %s 失败: %v", id, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// HealthCheck 按配置的间隔探测空闲模型，阻塞直到 ctx 结束；配置热加载后按新间隔继续
+func (r *Router) HealthCheck(ctx context.Context) {
+	for {
+		t := r.table.Load()
+		timer := time.NewTimer(time.Duration(t.cfg.Health.ProbeInterval) * time.Second)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			t.probeIdle(ctx)
+		}
+	}
+}
+
+// 单个 profile 的健康状况
+type ProfileHealth struct {
+	Profile   string
+	ModelID   string
+	Latency   time.Duration
+	ErrorRate float64
+	Samples   int64
+	LastUsed  time.Time
+	LastProbe time.Time
+	LastError string
+	Breaker   string
+	Group     string
+	Weight    float64 // 组内当前权重，不在组内时为 0
+}
+
+func (h ProfileHealth) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%-8s %-24s 延迟 %-8s 错误率 %5.1f%% 样本 %-4d 熔断 %-9s", h.Profile, h.ModelID,
+		h.Latency.Round(time.Millisecond), h.ErrorRate*100, h.Samples, h.Breaker)
+	if h.Group != "" {
+		fmt.Fprintf(&b, " 组 %s 权重 %.0f%%", h.Group, h.Weight*100)
+	}
+	if !h.LastProbe.IsZero() {
+		fmt.Fprintf(&b, " 上次探测 %s 前", time.Since(h.LastProbe).Round(time.Second))
+	}
+	if h.LastError != "" {
+		fmt.Fprintf(&b, " 最近错误：%s", h.LastError)
+	}
+	return b.String()
+}
+
+// 各 profile 的健康度、熔断状态与组内权重，按 profile 名排序
+func (r *Router) Health() []ProfileHealth {
+	t := r.table.Load()
+	weights := make(map[string]float64)
+	for group := range t.cfg.Health.Groups {
+		for name, w := range t.groupWeights(group, nil) {
+			weights[name] = w
+		}
+	}
+	names := make([]string, 0, len(t.models))
+	for name := range t.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := make([]ProfileHealth, 0, len(names))
+	for _, name := range names {
+		id := t.cfg.Profiles[name].modelID
+		h := t.pool.health(id)
+		h.mu.Lock()
+		ph := ProfileHealth{
+			Profile:   name,
+			ModelID:   id,
+			Latency:   time.Duration(h.latency * float64(time.Millisecond)),
+			ErrorRate: h.errRate,
+			Samples:   h.samples,
+			LastUsed:  h.lastUsed,
+			LastProbe: h.lastProbe,
+			LastError: h.lastErr,
+			Breaker:   t.pool.breaker(id).current().String(),
+			Group:     t.cfg.Health.groupOf[name],
+			Weight:    weights[name],
+		}
+		h.mu.Unlock()
+		out = append(out, ph)
+	}
+	return out
+}
@@ -0,0 +1,272 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 对冲请求：先发给主模型，DelayMS 内没有收到首个 token 就把同一请求发给备选模型，
+// 先返回首 token 的一方胜出，另一方被取消
+type hedgeConfig struct {
+	DelayMS int               `yaml:"delay_ms" json:"delay_ms"`
+	Pairs   map[string]string `yaml:"pairs" json:"pairs"` // 主 profile -> 备选 profile
+}
+
+func (c *hedgeConfig) validate(profiles map[string]*profileConfig) []error {
+	var errs []error
+	if c.DelayMS <= 0 {
+		errs = append(errs, fmt.Errorf("hedging.delay_ms 必须大于 0"))
+	}
+	for primary, secondary := range c.Pairs {
+		if _, ok := profiles[primary]; !ok {
+			errs = append(errs, fmt.Errorf("hedging.pairs 引用了未定义的 profile %q", primary))
+		}
+		if _, ok := profiles[secondary]; !ok {
+			errs = append(errs, fmt.Errorf("hedging.pairs 引用了未定义的 profile %q", secondary))
+		}
+		if primary == secondary {
+			errs = append(errs, fmt.Errorf("hedging.pairs 中 %s 不能与自身对冲", primary))
+		}
+	}
+	return errs
+}
+
+// 对冲统计，跨配置热加载累计
+type hedgeStats struct {
+	requests      atomic.Int64
+	fired         atomic.Int64
+	primaryWins   atomic.Int64
+	secondaryWins atomic.Int64
+	failures      atomic.Int64
+}
+
+type HedgeStats struct {
+	Requests      int64
+	Fired         int64
+	PrimaryWins   int64
+	SecondaryWins int64
+	Failures      int64
+}
+
+func (s HedgeStats) String() string {
+	rate := 0.0
+	if s.Requests > 0 {
+		rate = float64(s.Fired) * 100 / float64(s.Requests)
+	}
+	return fmt.Sprintf("对冲请求 %d 次，触发 %d 次（%.1f%%），主模型胜 %d，备选胜 %d，均失败 %d",
+		s.Requests, s.Fired, rate, s.PrimaryWins, s.SecondaryWins, s.Failures)
+}
+
+func (s *hedgeStats) snapshot() HedgeStats {
+	return HedgeStats{
+		Requests:      s.requests.Load(),
+		Fired:         s.fired.Load(),
+		PrimaryWins:   s.primaryWins.Load(),
+		SecondaryWins: s.secondaryWins.Load(),
+		Failures:      s.failures.Load(),
+	}
+}
+
+// 返回 profile 的对冲伙伴；未开启对冲或伙伴未配置模型、能力不足时返回 false
+func (t *routeTable) hedgePartner(profile string, need *requirements) (string, bool) {
+	if t.cfg.Hedging == nil {
+		return "", false
+	}
+	secondary, ok := t.cfg.Hedging.Pairs[profile]
+	if !ok || t.models[profile] == nil || !t.capable(secondary, need) {
+		return "", false
+	}
+	return secondary, true
+}
+
+type hedgeResult struct {
+	name    string
+	first   *schema.Message
+	stream  *schema.StreamReader[*schema.Message]
+	cancel  context.CancelFunc
+	latency time.Duration
+	err     error
+}
+
+// 以流式方式发起对冲，返回胜出方的完整流（首个 chunk 已重新拼回流的开头）
+func (t *routeTable) hedgedStream(ctx context.Context, primary, secondary string, stats *hedgeStats,
+	bind func(profile string) (model.BaseChatModel, error), in []*schema.Message, opts ...model.Option,
+) (*schema.StreamReader[*schema.Message], *CallRecord, error) {
+	record := &CallRecord{Requested: primary}
+	stats.requests.Add(1)
+
+	results := make(chan hedgeResult, 2)
+	cancels := make(map[string]context.CancelFunc, 2)
+	launch := func(name string) bool {
+		p := t.cfg.Profiles[name]
+		if !t.pool.breaker(p.modelID).allow(t.cfg.Breaker) {
+			record.Attempts = append(record.Attempts, Attempt{Profile: name, ModelID: p.modelID, Skipped: "熔断中"})
+			return false
+		}
+		callCtx, cancel := context.WithCancel(ctx)
+		cancels[name] = cancel
+		go func() {
+			start := time.Now()
+			res := hedgeResult{name: name, cancel: cancel}
+			defer func() {
+				res.latency = time.Since(start)
+				results <- res
+			}()
+			m, err := bind(name)
+			if err != nil {
+				res.err = err
+				return
+			}
+			sr, err := m.Stream(callCtx, in, opts...)
+			if err != nil {
+				res.err = err
+				return
+			}
+			first, err := sr.Recv()
+			if err != nil {
+				sr.Close()
+				if err == io.EOF {
+					err = errors.New("模型返回了空的流")
+				}
+				res.err = err
+				return
+			}
+			res.first, res.stream = first, sr
+		}()
+		return true
+	}
+
+	pending := 0
+	if launch(primary) {
+		pending++
+	}
+	fired := false
+	fire := func() {
+		if fired {
+			return
+		}
+		fired = true
+		stats.fired.Add(1)
+		log.Printf("🏁 主模型 %s 在 %dms 内无响应，对冲请求 %s", primary, t.cfg.Hedging.DelayMS, secondary)
+		if launch(secondary) {
+			pending++
+		}
+	}
+	if pending == 0 {
+		fire()
+	}
+
+	timer := time.NewTimer(time.Duration(t.cfg.Hedging.DelayMS) * time.Millisecond)
+	defer timer.Stop()
+
+	var lastErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			fire()
+		case res := <-results:
+			pending--
+			p := t.cfg.Profiles[res.name]
+			b := t.pool.breaker(p.modelID)
+			record.Attempts = append(record.Attempts, Attempt{Profile: res.name, ModelID: p.modelID, Latency: res.latency, Err: res.err})
+			if ctx.Err() == nil {
+				t.observe(p.modelID, res.latency, res.err)
+			}
+			if res.err != nil {
+				res.cancel()
+				if ctx.Err() != nil {
+					b.release()
+					go t.drainHedgeLosers(results, pending)
+					return nil, record, ctx.Err()
+				}
+				b.record(t.cfg.Breaker, !isRetryable(res.err))
+				lastErr = res.err
+				// 主模型在首 token 前就失败了，不必再等满延迟
+				if res.name == primary {
+					fire()
+				}
+				continue
+			}
+
+			b.record(t.cfg.Breaker, true)
+			record.Answered = res.name
+			if res.name == primary {
+				stats.primaryWins.Add(1)
+			} else {
+				stats.secondaryWins.Add(1)
+			}
+			// 取消仍在进行的另一方，并在后台回收它的结果
+			for name, cancel := range cancels {
+				if name != res.name && pending > 0 {
+					cancel()
+					record.Attempts = append(record.Attempts, Attempt{Profile: name, ModelID: t.cfg.Profiles[name].modelID, Skipped: "对冲落败已取消"})
+				}
+			}
+			if pending > 0 {
+				go t.drainHedgeLosers(results, pending)
+			}
+			return prependChunk(res.first, res.stream, res.cancel), record, nil
+		case <-ctx.Done():
+			go t.drainHedgeLosers(results, pending)
+			return nil, record, ctx.Err()
+		}
+	}
+
+	stats.failures.Add(1)
+	if lastErr == nil {
+		lastErr = errors.New("对冲双方均处于熔断状态")
+	}
+	return nil, record, fmt.Errorf("对冲请求 %s/%s 均失败: %w", primary, secondary, lastErr)
+}
+
+// 败方被取消时不计入熔断失败；若它也已拿到首 token，说明模型是健康的
+func (t *routeTable) drainHedgeLosers(results <-chan hedgeResult, n int) {
+	for i := 0; i < n; i++ {
+		res := <-results
+		res.cancel()
+		b := t.pool.breaker(t.cfg.Profiles[res.name].modelID)
+		switch {
+		case res.err == nil:
+			res.stream.Close()
+			b.record(t.cfg.Breaker, true)
+			t.observe(t.cfg.Profiles[res.name].modelID, res.latency, nil)
+		case errors.Is(res.err, context.Canceled):
+			b.release()
+		default:
+			b.record(t.cfg.Breaker, !isRetryable(res.err))
+		}
+	}
+}
+
+// 把已读出的首个 chunk 接回流的开头（first 为 nil 时只转发），流读完或被关闭时释放对应的 ctx
+func prependChunk(first *schema.Message, sr *schema.StreamReader[*schema.Message], cancel context.CancelFunc) *schema.StreamReader[*schema.Message] {
+	out, w := schema.Pipe[*schema.Message](1)
+	go func() {
+		defer cancel()
+		defer sr.Close()
+		defer w.Close()
+		if first != nil {
+			if closed := w.Send(first, nil); closed {
+				return
+			}
+		}
+		for {
+			chunk, err := sr.Recv()
+			if err == io.EOF {
+				return
+			}
+			if closed := w.Send(chunk, err); closed || err != nil {
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,167 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 单次请求最多的停止序列数，与 Ark / OpenAI 的限制一致
+const maxStopSequences = 4
+
+// profile 的默认生成参数：路由选中该 profile（含回退、对冲、级联、影子）时自动套用，
+// 调用方传入的 model.Option 排在后面，会覆盖同名参数
+type paramsConfig struct {
+	Temperature *float32 `yaml:"temperature" json:"temperature"`
+	TopP        *float32 `yaml:"top_p" json:"top_p"`
+	MaxTokens   *int     `yaml:"max_tokens" json:"max_tokens"`
+	Stop        []string `yaml:"stop" json:"stop"`
+	// 拼在系统提示词最前面；请求没有系统消息时单独作为一条系统消息
+	SystemPrefix string `yaml:"system_prefix" json:"system_prefix"`
+}
+
+func (c *paramsConfig) validate(name string, p *profileConfig) []error {
+	var errs []error
+	if c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2) {
+		errs = append(errs, fmt.Errorf("profile %s 的 params.temperature 必须在 [0,2] 之间，当前 %.2f", name, *c.Temperature))
+	}
+	if c.TopP != nil && (*c.TopP <= 0 || *c.TopP > 1) {
+		errs = append(errs, fmt.Errorf("profile %s 的 params.top_p 必须在 (0,1] 之间，当前 %.2f", name, *c.TopP))
+	}
+	if c.MaxTokens != nil {
+		if *c.MaxTokens <= 0 {
+			errs = append(errs, fmt.Errorf("profile %s 的 params.max_tokens 必须大于 0", name))
+		} else if p.MaxOutputTokens > 0 && *c.MaxTokens > p.MaxOutputTokens {
+			errs = append(errs, fmt.Errorf("profile %s 的 params.max_tokens(%d) 超过 max_output_tokens(%d)", name, *c.MaxTokens, p.MaxOutputTokens))
+		}
+	}
+	if len(c.Stop) > maxStopSequences {
+		errs = append(errs, fmt.Errorf("profile %s 的 params.stop 最多 %d 个，当前 %d 个", name, maxStopSequences, len(c.Stop)))
+	}
+	for _, s := range c.Stop {
+		if s == "" {
+			errs = append(errs, fmt.Errorf("profile %s 的 params.stop 不能包含空字符串", name))
+			break
+		}
+	}
+	return errs
+}
+
+func (c *paramsConfig) String() string {
+	if c == nil {
+		return ""
+	}
+	var parts []string
+	if c.Temperature != nil {
+		parts = append(parts, fmt.Sprintf("temperature=%.2g", *c.Temperature))
+	}
+	if c.TopP != nil {
+		parts = append(parts, fmt.Sprintf("top_p=%.2g", *c.TopP))
+	}
+	if c.MaxTokens != nil {
+		parts = append(parts, fmt.Sprintf("max_tokens=%d", *c.MaxTokens))
+	}
+	if len(c.Stop) > 0 {
+		parts = append(parts, fmt.Sprintf("stop=%q", c.Stop))
+	}
+	if c.SystemPrefix != "" {
+		parts = append(parts, "system_prefix")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Router 专有的调用参数，通过 model.Option 传入
+type routerOptions struct {
+	systemPrefix *string
+}
+
+// WithSystemPrefix 覆盖本次请求的系统提示词前缀，传空字符串表示不加前缀
+func WithSystemPrefix(prefix string) model.Option {
+	return model.WrapImplSpecificOptFn(func(o *routerOptions) {
+		o.systemPrefix = &prefix
+	})
+}
+
+// 在调用方参数之前插入 profile 的默认参数，并给系统提示词加上前缀；不修改调用方的消息
+func (c *paramsConfig) apply(in []*schema.Message, opts []model.Option) ([]*schema.Message, []model.Option) {
+	prefix := c.SystemPrefix
+	if o := model.GetImplSpecificOptions(&routerOptions{}, opts...); o.systemPrefix != nil {
+		prefix = *o.systemPrefix
+	}
+
+	defaults := make([]model.Option, 0, 4+len(opts))
+	if c.Temperature != nil {
+		defaults = append(defaults, model.WithTemperature(*c.Temperature))
+	}
+	if c.TopP != nil {
+		defaults = append(defaults, model.WithTopP(*c.TopP))
+	}
+	if c.MaxTokens != nil {
+		defaults = append(defaults, model.WithMaxTokens(*c.MaxTokens))
+	}
+	if len(c.Stop) > 0 {
+		defaults = append(defaults, model.WithStop(c.Stop))
+	}
+	opts = append(defaults, opts...)
+
+	if prefix == "" {
+		return in, opts
+	}
+	out := make([]*schema.Message, 0, len(in)+1)
+	if len(in) > 0 && in[0].Role == schema.System {
+		sys := *in[0]
+		sys.Content = strings.TrimSpace(prefix + "\n" + sys.Content)
+		out = append(out, &sys)
+		return append(out, in[1:]...), opts
+	}
+	out = append(out, schema.SystemMessage(prefix))
+	return append(out, in...), opts
+}
+
+// 套用 profile 生成参数的模型包装
+type paramsModel struct {
+	inner  model.BaseChatModel
+	params *paramsConfig
+}
+
+func (m *paramsModel) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	in, opts = m.params.apply(in, opts)
+	return m.inner.Generate(ctx, in, opts...)
+}
+
+func (m *paramsModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	in, opts = m.params.apply(in, opts)
+	return m.inner.Stream(ctx, in, opts...)
+}
+
+// 取出 profile 对应的模型，绑定 Router 上的工具并套用该 profile 的生成参数
+func (r *Router) bind(t *routeTable, profile string) (model.BaseChatModel, error) {
+	var m model.BaseChatModel = t.models[profile]
+	if len(r.tools) > 0 {
+		bound, err := t.models[profile].WithTools(r.tools)
+		if err != nil {
+			return nil, err
+		}
+		m = bound
+	}
+	return t.withParams(profile, m), nil
+}
+
+// 套用 profile 的生成参数，不绑定工具；用于审核等路由内部的辅助调用
+func (t *routeTable) withParams(profile string, m model.BaseChatModel) model.BaseChatModel {
+	params := t.cfg.Profiles[profile].Params
+	if params == nil {
+		// 没有默认参数时仍需包装，调用方可能通过 WithSystemPrefix 指定前缀
+		params = &paramsConfig{}
+	}
+	return &paramsModel{inner: m, params: params}
+}
+
+func (r *Router) binder(t *routeTable) func(profile string) (model.BaseChatModel, error) {
+	return func(profile string) (model.BaseChatModel, error) {
+		return r.bind(t, profile)
+	}
+}
@@ -0,0 +1,179 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 记录收到的消息与调用参数
+type captureModel struct {
+	in   []*schema.Message
+	opts *model.Options
+}
+
+func (m *captureModel) Generate(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.in, m.opts = in, model.GetCommonOptions(&model.Options{}, opts...)
+	return schema.AssistantMessage("ok", nil), nil
+}
+
+func (m *captureModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	resp, err := m.Generate(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{resp}), nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestParamsValidate(t *testing.T) {
+	profile := &profileConfig{MaxOutputTokens: 1000}
+	cases := []struct {
+		name   string
+		params paramsConfig
+		want   string
+	}{
+		{name: "合法参数", params: paramsConfig{Temperature: ptr[float32](0), TopP: ptr[float32](1), MaxTokens: ptr(1000), Stop: []string{"\n\n"}}},
+		{name: "temperature 过低", params: paramsConfig{Temperature: ptr[float32](-0.1)}, want: "params.temperature"},
+		{name: "temperature 过高", params: paramsConfig{Temperature: ptr[float32](2.5)}, want: "params.temperature"},
+		{name: "top_p 为 0", params: paramsConfig{TopP: ptr[float32](0)}, want: "params.top_p"},
+		{name: "top_p 大于 1", params: paramsConfig{TopP: ptr[float32](1.2)}, want: "params.top_p"},
+		{name: "max_tokens 非正", params: paramsConfig{MaxTokens: ptr(0)}, want: "params.max_tokens"},
+		{name: "max_tokens 超过 max_output_tokens", params: paramsConfig{MaxTokens: ptr(2000)}, want: "max_output_tokens"},
+		{name: "stop 过多", params: paramsConfig{Stop: []string{"a", "b", "c", "d", "e"}}, want: "params.stop"},
+		{name: "stop 含空字符串", params: paramsConfig{Stop: []string{""}}, want: "params.stop"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := tc.params.validate("p", profile)
+			if tc.want == "" {
+				if len(errs) > 0 {
+					t.Fatalf("期望通过校验，实际为 %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 || !strings.Contains(errs[0].Error(), tc.want) {
+				t.Fatalf("期望一个关于 %s 的错误，实际为 %v", tc.want, errs)
+			}
+		})
+	}
+}
+
+func TestParamsApplyOverrides(t *testing.T) {
+	inner := &captureModel{}
+	m := &paramsModel{inner: inner, params: &paramsConfig{
+		Temperature: ptr[float32](0.2),
+		TopP:        ptr[float32](0.9),
+		MaxTokens:   ptr(100),
+		Stop:        []string{"END"},
+	}}
+
+	// 调用方没传的参数用 profile 的默认值
+	if _, err := m.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")}); err != nil {
+		t.Fatal(err)
+	}
+	if o := inner.opts; *o.Temperature != 0.2 || *o.TopP != 0.9 || *o.MaxTokens != 100 || len(o.Stop) != 1 {
+		t.Errorf("默认参数未生效：%+v", o)
+	}
+
+	// 调用方传入的同名参数覆盖默认值，其余仍用默认值
+	if _, err := m.Generate(context.Background(), []*schema.Message{schema.UserMessage("hi")},
+		model.WithTemperature(0.8), model.WithMaxTokens(500)); err != nil {
+		t.Fatal(err)
+	}
+	if o := inner.opts; *o.Temperature != 0.8 || *o.MaxTokens != 500 || *o.TopP != 0.9 {
+		t.Errorf("调用方参数应覆盖默认值：temperature=%v max_tokens=%v top_p=%v", *o.Temperature, *o.MaxTokens, *o.TopP)
+	}
+}
+
+func TestParamsSystemPrefix(t *testing.T) {
+	params := &paramsConfig{SystemPrefix: "请用中文回答"}
+	cases := []struct {
+		name string
+		in   []*schema.Message
+		opts []model.Option
+		want []string // 各条消息的 role:content
+	}{
+		{
+			name: "没有系统消息时单独插入一条",
+			in:   []*schema.Message{schema.UserMessage("hi")},
+			want: []string{"system:请用中文回答", "user:hi"},
+		},
+		{
+			name: "拼在已有系统消息前面，不新增系统消息",
+			in:   []*schema.Message{schema.SystemMessage("你是助手"), schema.UserMessage("hi")},
+			want: []string{"system:请用中文回答\n你是助手", "user:hi"},
+		},
+		{
+			name: "WithSystemPrefix 覆盖 profile 的前缀",
+			in:   []*schema.Message{schema.SystemMessage("你是助手"), schema.UserMessage("hi")},
+			opts: []model.Option{WithSystemPrefix("简短回答")},
+			want: []string{"system:简短回答\n你是助手", "user:hi"},
+		},
+		{
+			name: "WithSystemPrefix 传空字符串表示不加前缀",
+			in:   []*schema.Message{schema.UserMessage("hi")},
+			opts: []model.Option{WithSystemPrefix("")},
+			want: []string{"user:hi"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := make([]string, len(tc.in))
+			for i, msg := range tc.in {
+				original[i] = msg.Content
+			}
+			out, _ := params.apply(tc.in, tc.opts)
+			var got []string
+			for _, msg := range out {
+				got = append(got, string(msg.Role)+":"+msg.Content)
+			}
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("消息 = %q，期望 %q", got, tc.want)
+			}
+			for i, msg := range tc.in {
+				if msg.Content != original[i] {
+					t.Errorf("不应修改调用方的消息，第 %d 条变成了 %q", i, msg.Content)
+				}
+			}
+		})
+	}
+}
+
+// 路由时按 profile 的 params.max_tokens 预留输出，而不是默认的 1024
+func TestRoutingUsesProfileMaxTokens(t *testing.T) {
+	routes := func(params string) string {
+		return `
+strategy: rules
+default_profile: small
+profiles:
+  small:
+    model: small-model
+    context_window: 600
+    fallbacks: [large]` + params + `
+  large:
+    model: large-model
+`
+	}
+	for _, tc := range []struct {
+		params, answered string
+	}{
+		{params: "", answered: "large"},
+		{params: "\n    params:\n      max_tokens: 200", answered: "small"},
+	} {
+		r := newMockRouter(t, routes(tc.params), "rules:\n  - content: ok\n")
+		_, _, record, err := r.GenerateWithRecord(context.Background(), []*schema.Message{schema.UserMessage("你好")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.Answered != tc.answered {
+			t.Errorf("params%q 时由 %s 应答，期望 %s", tc.params, record.Answered, tc.answered)
+		}
+	}
+}
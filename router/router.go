@@ -0,0 +1,207 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"agent-demo/llm"
+	"agent-demo/ratelimit"
+
+	"github.com/cloudwego/eino-ext/components/model/ark"
+)
+
+// 路由所需的请求特征
+type routeInput struct {
+	Query     string
+	HasImages bool
+	HasTools  bool
+}
+
+// 路由结果：选中的 profile 以及原因（命中的规则或分类标签），便于日志排查
+type Decision struct {
+	Profile string
+	Reason  string
+}
+
+// 按模型 ID 复用 ChatModel 实例、限流器、熔断与健康状态，配置热加载时不必重复创建
+type modelPool struct {
+	mu       sync.Mutex
+	base     llm.Config // 模型服务与连接信息，模型 ID 按 profile 填入
+	models   map[string]*ratelimit.ChatModel
+	breakers map[string]*circuitBreaker
+	limiters map[string]*ratelimit.Limiter
+	healths  map[string]*modelHealth
+}
+
+func newModelPool(base llm.Config) *modelPool {
+	return &modelPool{
+		base:     base,
+		models:   make(map[string]*ratelimit.ChatModel),
+		breakers: make(map[string]*circuitBreaker),
+		limiters: make(map[string]*ratelimit.Limiter),
+		healths:  make(map[string]*modelHealth),
+	}
+}
+
+func (p *modelPool) get(ctx context.Context, modelID string) (*ratelimit.ChatModel, error) {
+	return p.newModel(ctx, modelID, nil, true)
+}
+
+// 创建共享该模型 ID 限流器的模型；cache 为 false 时每次新建（如分类器需要独立的 ResponseFormat）
+func (p *modelPool) newModel(ctx context.Context, modelID string, format *ark.ResponseFormat, cache bool) (*ratelimit.ChatModel, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if m, ok := p.models[modelID]; ok && cache {
+		return m, nil
+	}
+	c := p.base
+	c.Model, c.ResponseFormat = modelID, format
+	m, err := llm.NewChatModelWithLimiter(ctx, c, p.limiterLocked(modelID))
+	if err != nil {
+		return nil, err
+	}
+	if cache {
+		p.models[modelID] = m
+	}
+	return m, nil
+}
+
+func (p *modelPool) limiter(modelID string) *ratelimit.Limiter {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.limiterLocked(modelID)
+}
+
+func (p *modelPool) limiterLocked(modelID string) *ratelimit.Limiter {
+	l, ok := p.limiters[modelID]
+	if !ok {
+		l = ratelimit.NewLimiter(ratelimit.Config{})
+		p.limiters[modelID] = l
+	}
+	return l
+}
+
+// 各模型 ID 的限流统计
+func (p *modelPool) limiterStats() map[string]ratelimit.Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make(map[string]ratelimit.Stats, len(p.limiters))
+	for id, l := range p.limiters {
+		out[id] = l.Stats()
+	}
+	return out
+}
+
+// 一份生效中的路由表：配置 + 各 profile 对应的模型实例（未配置模型 ID 的 profile 不在其中）
+type routeTable struct {
+	cfg        *routerConfig
+	pool       *modelPool
+	models     map[string]*ratelimit.ChatModel
+	classifier *intentClassifier
+	semantic   []semanticExample
+}
+
+func buildRouteTable(ctx context.Context, cfg *routerConfig, pool *modelPool) (*routeTable, error) {
+	t := &routeTable{cfg: cfg, pool: pool, models: make(map[string]*ratelimit.ChatModel)}
+	limits := make(map[string]ratelimit.Config)
+	for name, p := range cfg.Profiles {
+		if p.modelID == "" {
+			continue
+		}
+		m, err := pool.get(ctx, p.modelID)
+		if err != nil {
+			return nil, fmt.Errorf("初始化模型 %s 失败: %w", name, err)
+		}
+		t.models[name] = m
+		if _, ok := limits[p.modelID]; !ok || p.RateLimit != nil {
+			limits[p.modelID] = ratelimit.Config{}
+			if p.RateLimit != nil {
+				limits[p.modelID] = *p.RateLimit
+			}
+		}
+	}
+	// 限额按模型 ID 生效；配置中删掉的限额在重载后恢复为不限
+	for id, limit := range limits {
+		pool.limiter(id).SetConfig(limit)
+	}
+	if cfg.Strategy == strategyClassifier {
+		c, err := newIntentClassifier(ctx, cfg.Classifier, pool, cfg.Profiles[cfg.Classifier.Profile].modelID)
+		if err != nil {
+			return nil, fmt.Errorf("初始化意图分类器失败: %w", err)
+		}
+		t.classifier = c
+	}
+	if cfg.Strategy == strategySemantic {
+		t.semantic = buildSemanticIndex(cfg)
+	}
+	return t, nil
+}
+
+// 按配置的策略选择 profile
+func (t *routeTable) route(ctx context.Context, in routeInput) Decision {
+	switch t.cfg.Strategy {
+	case strategyClassifier:
+		return t.classifyRoute(ctx, in)
+	case strategySemantic:
+		return t.semanticRoute(in)
+	case strategyCascade:
+		// 级联在调用时才决定最终模型，这里只给出起始级别
+		if stages := t.cascadeStages(nil); len(stages) > 0 {
+			return Decision{Profile: stages[0], Reason: "cascade:起始级"}
+		}
+		return Decision{Profile: t.cfg.DefaultProfile, Reason: "cascade:无可用级别"}
+	default:
+		profile, rule := t.selectModel(in)
+		return Decision{Profile: profile, Reason: "rule:" + rule}
+	}
+}
+
+// 返回命中的 profile 与规则名；目标模型未配置的规则会被跳过
+func (t *routeTable) selectModel(in routeInput) (profile, rule string) {
+	for _, r := range t.cfg.Rules {
+		if t.models[r.Profile] == nil {
+			continue
+		}
+		if r.match(in) {
+			return r.Profile, r.Name
+		}
+	}
+	return t.cfg.DefaultProfile, "default"
+}
+
+func (r *ruleConfig) match(in routeInput) bool {
+	if r.HasImages != nil && *r.HasImages != in.HasImages {
+		return false
+	}
+	if r.HasTools != nil && *r.HasTools != in.HasTools {
+		return false
+	}
+	length := len([]rune(in.Query))
+	if r.MinLength > 0 && length < r.MinLength {
+		return false
+	}
+	if r.MaxLength > 0 && length > r.MaxLength {
+		return false
+	}
+	if len(r.Keywords) > 0 || len(r.compiled) > 0 {
+		return r.matchText(in.Query)
+	}
+	return true
+}
+
+func (r *ruleConfig) matchText(query string) bool {
+	lower := strings.ToLower(query)
+	for _, kw := range r.Keywords {
+		if strings.Contains(lower, strings.ToLower(kw)) {
+			return true
+		}
+	}
+	for _, re := range r.compiled {
+		if re.MatchString(query) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package router
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"agent-demo/textvec"
+)
+
+// 语义路由：每个 profile 配若干示例语句，查询与示例向量做余弦相似度，
+// 最相近的示例超过阈值时路由到其所属 profile，否则回退到规则
+type semanticConfig struct {
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+}
+
+type semanticExample struct {
+	Profile string
+	Text    string
+	Vector  map[string]float64
+}
+
+func (c *semanticConfig) validate(profiles map[string]*profileConfig) []error {
+	var errs []error
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		errs = append(errs, fmt.Errorf("semantic.threshold 必须在 (0,1] 之间，当前 %.2f", c.Threshold))
+	}
+	examples := 0
+	for _, p := range profiles {
+		if p != nil {
+			examples += len(p.Examples)
+		}
+	}
+	if examples == 0 {
+		errs = append(errs, fmt.Errorf("semantic 策略需要至少一个 profile 配置 examples"))
+	}
+	return errs
+}
+
+// 只为已配置模型的 profile 建立示例索引；按 profile 名排序，得分相同时固定选排在前面的
+func buildSemanticIndex(cfg *routerConfig) []semanticExample {
+	var index []semanticExample
+	for _, name := range slices.Sorted(maps.Keys(cfg.Profiles)) {
+		p := cfg.Profiles[name]
+		if p.modelID == "" {
+			continue
+		}
+		for _, ex := range p.Examples {
+			index = append(index, semanticExample{Profile: name, Text: ex, Vector: textvec.FromTextWithBigrams(ex)})
+		}
+	}
+	return index
+}
+
+func (t *routeTable) semanticRoute(in routeInput) Decision {
+	qVec := textvec.FromTextWithBigrams(in.Query)
+	var best *semanticExample
+	bestScore := 0.0
+	for i := range t.semantic {
+		score := textvec.Cosine(qVec, t.semantic[i].Vector)
+		if score > bestScore {
+			best, bestScore = &t.semantic[i], score
+		}
+	}
+	if best != nil && bestScore >= t.cfg.Semantic.Threshold {
+		return Decision{Profile: best.Profile, Reason: fmt.Sprintf("semantic:%.2f「%s」", bestScore, best.Text)}
+	}
+	profile, rule := t.selectModel(in)
+	return Decision{Profile: profile, Reason: fmt.Sprintf("semantic:%.2f 低于阈值，回退规则 %s", bestScore, rule)}
+}
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// 两个 profile 的示例与查询同样相似时，每次都路由到按名称排在前面的那个
+func TestSemanticTieBreakIsStable(t *testing.T) {
+	r := newMockRouter(t, `
+strategy: semantic
+default_profile: fallback
+semantic:
+  threshold: 0.5
+profiles:
+  fallback:
+    model: fallback-model
+  zeta:
+    model: zeta-model
+    examples: [帮我写一个排序函数]
+  alpha:
+    model: alpha-model
+    examples: [帮我写一个排序函数]
+  mid:
+    model: mid-model
+    examples: [帮我写一个排序函数]
+`, "rules:\n  - content: ok\n")
+
+	tbl := r.table.Load()
+	for range 20 {
+		var names []string
+		for _, ex := range buildSemanticIndex(tbl.cfg) {
+			names = append(names, ex.Profile)
+		}
+		if strings.Join(names, ",") != "alpha,mid,zeta" {
+			t.Fatalf("示例索引应按 profile 名排序，实际为 %v", names)
+		}
+	}
+	for range 5 {
+		_, decision, _, err := r.GenerateWithRecord(context.Background(), []*schema.Message{schema.UserMessage("帮我写一个排序函数")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Profile != "alpha" {
+			t.Fatalf("得分相同时应选 alpha，实际为 %s（%s）", decision.Profile, decision.Reason)
+		}
+	}
+}
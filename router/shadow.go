@@ -0,0 +1,233 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 影子流量：把请求异步复制一份发给候选模型，结果与线上回答成对写入 JSONL，供离线对比。
+// 影子调用与用户请求完全解耦：不阻塞、不影响回答，并发已满时直接丢弃
+type shadowConfig struct {
+	Profile        string  `yaml:"profile" json:"profile"`         // 候选模型
+	SampleRate     float64 `yaml:"sample_rate" json:"sample_rate"` // 不填为 1，即全部复制
+	MaxConcurrency int     `yaml:"max_concurrency" json:"max_concurrency"`
+	TimeoutMS      int     `yaml:"timeout_ms" json:"timeout_ms"`
+	Output         string  `yaml:"output" json:"output"`
+}
+
+func (c *shadowConfig) validate(profiles map[string]*profileConfig) []error {
+	var errs []error
+	if p := profiles[c.Profile]; p == nil || p.modelID == "" {
+		errs = append(errs, fmt.Errorf("shadow.profile %q 未定义或未配置模型", c.Profile))
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = 1
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		errs = append(errs, fmt.Errorf("shadow.sample_rate 必须在 [0,1] 之间，当前 %.2f", c.SampleRate))
+	}
+	if c.Output == "" {
+		errs = append(errs, errors.New("shadow.output 不能为空"))
+	}
+	if c.MaxConcurrency <= 0 {
+		c.MaxConcurrency = 4
+	}
+	if c.TimeoutMS <= 0 {
+		c.TimeoutMS = 60000
+	}
+	return errs
+}
+
+// 一条成对记录
+type shadowRecord struct {
+	Time           time.Time          `json:"time"`
+	Query          string             `json:"query"`
+	Reason         string             `json:"reason"`
+	PrimaryProfile string             `json:"primary_profile"`
+	PrimaryModel   string             `json:"primary_model"`
+	PrimaryOutput  string             `json:"primary_output"`
+	PrimaryTools   []schema.ToolCall  `json:"primary_tool_calls,omitempty"`
+	PrimaryMS      int64              `json:"primary_ms"`
+	PrimaryUsage   *schema.TokenUsage `json:"primary_usage,omitempty"`
+	ShadowProfile  string             `json:"shadow_profile"`
+	ShadowModel    string             `json:"shadow_model"`
+	ShadowOutput   string             `json:"shadow_output,omitempty"`
+	ShadowTools    []schema.ToolCall  `json:"shadow_tool_calls,omitempty"`
+	ShadowMS       int64              `json:"shadow_ms"`
+	ShadowUsage    *schema.TokenUsage `json:"shadow_usage,omitempty"`
+	ShadowError    string             `json:"shadow_error,omitempty"`
+}
+
+// 影子调用的运行状态挂在 Router 上，跨配置热加载保留；并发上限变化时按新值重建信号量
+type shadowRunner struct {
+	mu      sync.Mutex
+	slots   chan struct{}
+	writeMu sync.Mutex
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+type ShadowStats struct {
+	Sent    int64
+	Dropped int64
+	Failed  int64
+}
+
+func (s ShadowStats) String() string {
+	return fmt.Sprintf("影子请求 %d 次，并发已满丢弃 %d 次，候选模型失败 %d 次", s.Sent, s.Dropped, s.Failed)
+}
+
+func (s *shadowRunner) snapshot() ShadowStats {
+	return ShadowStats{Sent: s.sent.Load(), Dropped: s.dropped.Load(), Failed: s.failed.Load()}
+}
+
+func (s *shadowRunner) acquire(limit int) (release func(), ok bool) {
+	s.mu.Lock()
+	if s.slots == nil || cap(s.slots) != limit {
+		s.slots = make(chan struct{}, limit)
+	}
+	slots := s.slots
+	s.mu.Unlock()
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, true
+	default:
+		return nil, false
+	}
+}
+
+func (s *shadowRunner) write(path string, rec *shadowRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 线上回答完成后调用：按采样率决定是否复制请求，影子调用在后台进行
+func (r *Router) shadow(ctx context.Context, t *routeTable, in []*schema.Message, opts []model.Option,
+	resp *schema.Message, decision Decision, record *CallRecord, latency time.Duration,
+) {
+	cfg := t.cfg.Shadow
+	if cfg == nil || resp == nil || record.Answered == cfg.Profile || !t.capable(cfg.Profile, r.requirementsOf(in, opts)) {
+		return
+	}
+	if cfg.SampleRate < 1 && rand.Float64() >= cfg.SampleRate {
+		return
+	}
+	release, ok := r.shadows.acquire(cfg.MaxConcurrency)
+	if !ok {
+		r.shadows.dropped.Add(1)
+		return
+	}
+	r.shadows.sent.Add(1)
+
+	rec := &shadowRecord{
+		Time:           time.Now(),
+		Query:          r.inputOf(in).Query,
+		Reason:         decision.Reason,
+		PrimaryProfile: record.Answered,
+		PrimaryModel:   t.cfg.Profiles[record.Answered].modelID,
+		PrimaryOutput:  resp.Content,
+		PrimaryTools:   resp.ToolCalls,
+		PrimaryMS:      latency.Milliseconds(),
+		ShadowProfile:  cfg.Profile,
+		ShadowModel:    t.cfg.Profiles[cfg.Profile].modelID,
+	}
+	if resp.ResponseMeta != nil {
+		rec.PrimaryUsage = resp.ResponseMeta.Usage
+	}
+	// 保留 ctx 中的值，但不随用户请求一起取消
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(cfg.TimeoutMS)*time.Millisecond)
+	go func() {
+		defer release()
+		defer cancel()
+		start := time.Now()
+		out, err := func() (*schema.Message, error) {
+			bound, err := r.bind(t, cfg.Profile)
+			if err != nil {
+				return nil, err
+			}
+			return bound.Generate(ctx, in, opts...)
+		}()
+		rec.ShadowMS = time.Since(start).Milliseconds()
+		if err != nil {
+			r.shadows.failed.Add(1)
+			rec.ShadowError = err.Error()
+		} else {
+			rec.ShadowOutput = out.Content
+			rec.ShadowTools = out.ToolCalls
+			if out.ResponseMeta != nil {
+				rec.ShadowUsage = out.ResponseMeta.Usage
+			}
+		}
+		if err := r.shadows.write(cfg.Output, rec); err != nil {
+			log.Printf("⚠️ 写入影子流量记录失败: %v", err)
+		}
+	}()
+}
+
+// 流式请求：复制一份流在后台读完并拼成完整回答后再发起影子调用，用户读到的流不受影响
+func (r *Router) shadowStream(ctx context.Context, t *routeTable, in []*schema.Message, opts []model.Option,
+	sr *schema.StreamReader[*schema.Message], decision Decision, record *CallRecord,
+) *schema.StreamReader[*schema.Message] {
+	if t.cfg.Shadow == nil || record.Answered == t.cfg.Shadow.Profile {
+		return sr
+	}
+	copies := sr.Copy(2)
+	start := time.Now()
+	go func() {
+		tee := copies[1]
+		defer tee.Close()
+		var chunks []*schema.Message
+		for {
+			chunk, err := tee.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return
+			}
+			chunks = append(chunks, chunk)
+		}
+		if len(chunks) == 0 {
+			return
+		}
+		resp, err := schema.ConcatMessages(chunks)
+		if err != nil {
+			return
+		}
+		r.shadow(ctx, t, in, opts, resp, decision, record, time.Since(start))
+	}()
+	return copies[0]
+}
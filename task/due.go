@@ -0,0 +1,345 @@
+package task
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 只说了时段没说几点时采用的钟点
+var periodHours = map[string]int{
+	"凌晨": 6,
+	"早上": 8,
+	"早晨": 8,
+	"上午": 9,
+	"中午": 12,
+	"下午": 15,
+	"傍晚": 18,
+	"晚上": 20,
+	"夜里": 22,
+}
+
+// 可以直接解析的绝对时间格式，不带时区的按 loc 解释
+var dueLayouts = []string{
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04",
+	"2006-01-02",
+	"2006/01/02",
+}
+
+var cnDigitValues = map[rune]int{
+	'零': 0, '〇': 0, '一': 1, '二': 2, '两': 2, '三': 3, '四': 4,
+	'五': 5, '六': 6, '七': 7, '八': 8, '九': 9,
+}
+
+const cnDigits = `\d{1,4}|[零〇一二两三四五六七八九十]{1,3}`
+
+var (
+	reRelative = regexp.MustCompile(`^(` + cnDigits + `|半)个?(分钟|小时|钟头|天|周|星期|礼拜)(?:后|以后|之后)$`)
+	reDay      = regexp.MustCompile(`^(今天|今日|明天|明日|大后天|后天)`)
+	reWeekday  = regexp.MustCompile(`^(下下个?|下个?|本|这个?)?(?:周|星期|礼拜)([一二三四五六日天1-7])`)
+	reWeekend  = regexp.MustCompile(`^(下个?|本|这个?)?周末`)
+	reFullDate = regexp.MustCompile(`^(\d{4})年(` + cnDigits + `)月(` + cnDigits + `)[日号]`)
+	reMonthDay = regexp.MustCompile(`^(` + cnDigits + `)月(` + cnDigits + `)[日号]`)
+	reNextDay  = regexp.MustCompile(`^下个?月(` + cnDigits + `)[日号]`)
+	reDayOnly  = regexp.MustCompile(`^(` + cnDigits + `)[日号]`)
+	reMonthEnd = regexp.MustCompile(`^(下个?月|本月|这个?月)?月?底`)
+	rePeriod   = regexp.MustCompile(`^(凌晨|早上|早晨|上午|中午|下午|傍晚|晚上|夜里)`)
+	reClock    = regexp.MustCompile(`^(` + cnDigits + `)(?:[:：](\d{2})|[点时]钟?(?:(半)|(一刻)|(三刻)|(` + cnDigits + `)分?)?)`)
+)
+
+// ParseDue 把截止时间的描述解析为 loc 时区下的时间点，相对说法以 now 为基准。支持：
+//
+//	绝对时间：2025-03-05、2025-03-05 15:00、RFC3339
+//	相对时间：30分钟后、两小时后、3天后、一周后
+//	日期：今天 / 明天 / 后天、周三 / 下周三 / 下下周一、周末、3月5日、2025年3月5日、15号、月底
+//	时间：下午、晚上、下午3点、三点钟、3点半、15:30、九点一刻（只有时段时取该时段的典型钟点）
+//
+// 只给日期时取当天 23:59；只给时间且已经过了时取第二天；没说时段的“1~6点”（包括 3:30 这种写法）视为下午，
+// 凌晨需要说明时段；“晚上12点”“24点”指当天结束，即第二天 00:00。解析结果可能早于 now，是否接受由调用方决定
+func ParseDue(text string, now time.Time, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	raw := strings.TrimSpace(text)
+	if raw == "" {
+		return time.Time{}, fmt.Errorf("截止时间为空")
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range dueLayouts {
+		if t, err := time.ParseInLocation(layout, raw, loc); err == nil {
+			if !strings.Contains(layout, "15") {
+				t = endOfDay(t)
+			}
+			return t, nil
+		}
+	}
+
+	s := normalizeDue(raw)
+	if m := reRelative.FindStringSubmatch(s); m != nil {
+		return relative(now, m[1], m[2])
+	}
+
+	rest := s
+	date, hasDate, err := parseDate(&rest, now)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无法识别截止时间 %q: %w", raw, err)
+	}
+	hour, minute, hasTime, err := parseClock(&rest)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无法识别截止时间 %q: %w", raw, err)
+	}
+	if rest != "" || (!hasDate && !hasTime) {
+		return time.Time{}, fmt.Errorf("无法识别截止时间 %q，可以写成“下周三下午”“明天 15:00”或“2025-03-05 18:00”", raw)
+	}
+
+	if !hasDate {
+		date = now
+	}
+	if !hasTime {
+		return endOfDay(date), nil
+	}
+	t := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, loc)
+	if !hasDate && t.Before(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// 去掉空白和不影响含义的字，并把“今晚”“明早”这类合写展开
+func normalizeDue(s string) string {
+	s = strings.Join(strings.Fields(s), "")
+	for _, suffix := range []string{"之前", "以前", "前"} {
+		s = strings.TrimSuffix(s, suffix)
+	}
+	return strings.NewReplacer(
+		"的", "",
+		"今晚", "今天晚上",
+		"明早", "明天早上",
+		"明晚", "明天晚上",
+		"星期天", "星期日",
+		"礼拜天", "礼拜日",
+		"周天", "周日",
+	).Replace(s)
+}
+
+func relative(now time.Time, amount, unit string) (time.Time, error) {
+	if amount == "半" {
+		if unit == "小时" || unit == "钟头" {
+			return now.Add(30 * time.Minute), nil
+		}
+		return time.Time{}, fmt.Errorf("无法识别截止时间 “半%s后”", unit)
+	}
+	n, ok := parseNumber(amount)
+	if !ok {
+		return time.Time{}, fmt.Errorf("无法识别数字 %q", amount)
+	}
+	switch unit {
+	case "分钟":
+		return now.Add(time.Duration(n) * time.Minute), nil
+	case "小时", "钟头":
+		return now.Add(time.Duration(n) * time.Hour), nil
+	case "天":
+		return now.AddDate(0, 0, n), nil
+	default: // 周 / 星期 / 礼拜
+		return now.AddDate(0, 0, 7*n), nil
+	}
+}
+
+// 从 rest 开头解析日期并消耗掉匹配的部分
+func parseDate(rest *string, now time.Time) (time.Time, bool, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	consume := func(m []string) { *rest = (*rest)[len(m[0]):] }
+
+	if m := reDay.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		offset := map[string]int{"今天": 0, "今日": 0, "明天": 1, "明日": 1, "后天": 2, "大后天": 3}[m[1]]
+		return today.AddDate(0, 0, offset), true, nil
+	}
+
+	// 一周从周一开始
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	if m := reWeekday.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		day := slices.Index([]rune("一二三四五六日"), []rune(m[2])[0]) + 1
+		if day == 0 { // “天”已在 normalizeDue 中换成“日”，这里只剩阿拉伯数字
+			day, _ = strconv.Atoi(m[2])
+		}
+		t := monday.AddDate(0, 0, day-1)
+		switch {
+		case strings.HasPrefix(m[1], "下下"):
+			t = t.AddDate(0, 0, 14)
+		case strings.HasPrefix(m[1], "下"):
+			t = t.AddDate(0, 0, 7)
+		case m[1] == "" && t.Before(today):
+			// 只说“周一”且本周已经过了，指的是下周
+			t = t.AddDate(0, 0, 7)
+		}
+		return t, true, nil
+	}
+	if m := reWeekend.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		t := monday.AddDate(0, 0, 5)
+		switch {
+		case strings.HasPrefix(m[1], "下"):
+			t = t.AddDate(0, 0, 7)
+		case t.Before(today):
+			// 周日说“周末”就是今天
+			t = today
+		}
+		return t, true, nil
+	}
+
+	if m := reFullDate.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		year, _ := strconv.Atoi(m[1])
+		return makeDate(year, m[2], m[3], now.Location())
+	}
+	if m := reMonthDay.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		// “3月5日”已经过了指的是明年
+		t, ok, err := makeDate(today.Year(), m[1], m[2], now.Location())
+		if err == nil && t.Before(today) {
+			t = t.AddDate(1, 0, 0)
+		}
+		return t, ok, err
+	}
+	// 本月与下月的 1 日
+	first := today.AddDate(0, 0, -today.Day()+1)
+	next := first.AddDate(0, 1, 0)
+	if m := reNextDay.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		return makeDate(next.Year(), strconv.Itoa(int(next.Month())), m[1], now.Location())
+	}
+	if m := reDayOnly.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		// 只有“15号”：本月的这一天，已经过了则是下个月
+		t, ok, err := makeDate(first.Year(), strconv.Itoa(int(first.Month())), m[1], now.Location())
+		if err == nil && t.Before(today) {
+			t, ok, err = makeDate(next.Year(), strconv.Itoa(int(next.Month())), m[1], now.Location())
+		}
+		return t, ok, err
+	}
+	if m := reMonthEnd.FindStringSubmatch(*rest); m != nil {
+		consume(m)
+		if strings.HasPrefix(m[1], "下") {
+			return next.AddDate(0, 1, -1), true, nil
+		}
+		return next.AddDate(0, 0, -1), true, nil
+	}
+	return time.Time{}, false, nil
+}
+
+func makeDate(year int, month, day string, loc *time.Location) (time.Time, bool, error) {
+	mo, ok1 := parseNumber(month)
+	d, ok2 := parseNumber(day)
+	if !ok1 || !ok2 || mo < 1 || mo > 12 || d < 1 || d > 31 {
+		return time.Time{}, false, fmt.Errorf("日期 %s月%s日 无效", month, day)
+	}
+	t := time.Date(year, time.Month(mo), d, 0, 0, 0, 0, loc)
+	if t.Day() != d {
+		return time.Time{}, false, fmt.Errorf("%d月没有%d日", mo, d)
+	}
+	return t, true, nil
+}
+
+// 从 rest 开头解析“时段 + 钟点”，两者都可以省略其一
+func parseClock(rest *string) (hour, minute int, ok bool, err error) {
+	period := ""
+	if m := rePeriod.FindStringSubmatch(*rest); m != nil {
+		period = m[1]
+		*rest = (*rest)[len(m[0]):]
+	}
+	m := reClock.FindStringSubmatch(*rest)
+	if m == nil {
+		if period == "" {
+			return 0, 0, false, nil
+		}
+		return periodHours[period], 0, true, nil
+	}
+	*rest = (*rest)[len(m[0]):]
+
+	hour, _ = parseNumber(m[1])
+	switch {
+	case m[2] != "":
+		minute, _ = strconv.Atoi(m[2])
+	case m[3] != "":
+		minute = 30
+	case m[4] != "":
+		minute = 15
+	case m[5] != "":
+		minute = 45
+	case m[6] != "":
+		minute, _ = parseNumber(m[6])
+	}
+	if hour > 24 || minute > 59 || (hour == 24 && minute > 0) {
+		return 0, 0, false, fmt.Errorf("时间 %s 无效", m[0])
+	}
+	// 12 小时制：下午 3 点是 15 点，中午 1 点是 13 点；没说时段的“1~6点”按下午理解。
+	// 24 点与晚上 12 点由调用方按 time.Date 的规则进位到第二天 0 点
+	switch period {
+	case "":
+		if hour >= 1 && hour <= 6 {
+			hour += 12
+		}
+	case "凌晨":
+		if hour == 12 {
+			hour = 0
+		}
+	case "下午", "傍晚":
+		if hour < 12 {
+			hour += 12
+		}
+	case "晚上", "夜里":
+		if hour <= 12 {
+			hour += 12
+		}
+	case "中午":
+		if hour < 11 {
+			hour += 12
+		}
+	}
+	return hour, minute, true, nil
+}
+
+// 阿拉伯数字或不超过九十九的中文数字
+func parseNumber(s string) (int, bool) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return n, true
+	}
+	digit := func(r rune) (int, bool) {
+		d, ok := cnDigitValues[r]
+		return d, ok
+	}
+	runes := []rune(s)
+	switch {
+	case len(runes) == 1 && runes[0] == '十':
+		return 10, true
+	case len(runes) == 1:
+		return digit(runes[0])
+	case len(runes) == 2 && runes[0] == '十':
+		d, ok := digit(runes[1])
+		return 10 + d, ok
+	case len(runes) == 2 && runes[1] == '十':
+		d, ok := digit(runes[0])
+		return d * 10, ok
+	case len(runes) == 3 && runes[1] == '十':
+		tens, ok1 := digit(runes[0])
+		ones, ok2 := digit(runes[2])
+		return tens*10 + ones, ok1 && ok2
+	}
+	return 0, false
+}
+
+func endOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 0, 0, t.Location())
+}
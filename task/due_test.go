@@ -0,0 +1,97 @@
+package task
+
+import (
+	"testing"
+	"time"
+)
+
+var shanghai = time.FixedZone("CST", 8*3600)
+
+func TestParseDue(t *testing.T) {
+	// 2025-03-05 是周三
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, shanghai)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, shanghai)
+	}
+
+	cases := []struct {
+		text string
+		want time.Time
+	}{
+		{"2025-03-06", at(3, 6, 23, 59)},
+		{"2025-03-06 15:00", at(3, 6, 15, 0)},
+		{"2025-03-06T08:00:00Z", at(3, 6, 16, 0)},
+		{"30分钟后", at(3, 5, 10, 30)},
+		{"两小时后", at(3, 5, 12, 0)},
+		{"半小时后", at(3, 5, 10, 30)},
+		{"3天后", at(3, 8, 10, 0)},
+		{"一周后", at(3, 12, 10, 0)},
+		{"明天", at(3, 6, 23, 59)},
+		{"明天之前", at(3, 6, 23, 59)},
+		{"明天下午", at(3, 6, 15, 0)},
+		{"明天下午三点钟", at(3, 6, 15, 0)},
+		{"下午3点", at(3, 5, 15, 0)},
+		{"3点半", at(3, 5, 15, 30)},
+		{"3:30", at(3, 5, 15, 30)},
+		{"15:30", at(3, 5, 15, 30)},
+		{"十点二十分", at(3, 5, 10, 20)},
+		// 今天的 9:15 已经过了，指明天
+		{"九点一刻", at(3, 6, 9, 15)},
+		{"中午1点", at(3, 5, 13, 0)},
+		{"凌晨3点", at(3, 6, 3, 0)},
+		{"晚上12点", at(3, 6, 0, 0)},
+		{"今晚12点", at(3, 6, 0, 0)},
+		{"夜里12点半", at(3, 6, 0, 30)},
+		{"24点", at(3, 6, 0, 0)},
+		{"周五", at(3, 7, 23, 59)},
+		{"周一", at(3, 10, 23, 59)},
+		{"星期天", at(3, 9, 23, 59)},
+		{"下周三下午", at(3, 12, 15, 0)},
+		{"下下周一", at(3, 17, 23, 59)},
+		{"周末", at(3, 8, 23, 59)},
+		{"下周末", at(3, 15, 23, 59)},
+		{"3月5日", at(3, 5, 23, 59)},
+		{"3月1日", time.Date(2026, 3, 1, 23, 59, 0, 0, shanghai)},
+		{"2025年12月25日上午10点", at(12, 25, 10, 0)},
+		{"15号", at(3, 15, 23, 59)},
+		{"1号", at(4, 1, 23, 59)},
+		{"下个月5号", at(4, 5, 23, 59)},
+		{"月底", at(3, 31, 23, 59)},
+		{"下个月底", at(4, 30, 23, 59)},
+	}
+	for _, tc := range cases {
+		got, err := ParseDue(tc.text, now, shanghai)
+		if err != nil {
+			t.Errorf("ParseDue(%q) 出错: %v", tc.text, err)
+			continue
+		}
+		if !got.Equal(tc.want) {
+			t.Errorf("ParseDue(%q) = %s，期望 %s", tc.text, got.Format(time.DateTime), tc.want.Format(time.DateTime))
+		}
+	}
+}
+
+func TestParseDueInvalid(t *testing.T) {
+	now := time.Date(2025, 3, 5, 10, 0, 0, 0, shanghai)
+	for _, text := range []string{"", "随便什么时候", "25点", "3点70分", "24点半", "2月30日", "明天下午随便"} {
+		if got, err := ParseDue(text, now, shanghai); err == nil {
+			t.Errorf("ParseDue(%q) = %s，期望报错", text, got.Format(time.DateTime))
+		}
+	}
+}
+
+// 周日说“本周一”“周末”：前者按字面解析到已经过去的周一，由调用方拒绝；后者就是今天
+func TestParseDueOnSunday(t *testing.T) {
+	now := time.Date(2025, 3, 9, 10, 0, 0, 0, shanghai)
+	cases := map[string]time.Time{
+		"本周一": time.Date(2025, 3, 3, 23, 59, 0, 0, shanghai),
+		"周一":  time.Date(2025, 3, 10, 23, 59, 0, 0, shanghai),
+		"周末":  time.Date(2025, 3, 9, 23, 59, 0, 0, shanghai),
+	}
+	for text, want := range cases {
+		got, err := ParseDue(text, now, shanghai)
+		if err != nil || !got.Equal(want) {
+			t.Errorf("ParseDue(%q) = %s, %v，期望 %s", text, got.Format(time.DateTime), err, want.Format(time.DateTime))
+		}
+	}
+}
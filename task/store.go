@@ -0,0 +1,290 @@
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrNotFound = errors.New("任务不存在")
+
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityMedium Priority = "medium"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// 按紧急程度从低到高
+var priorities = []Priority{PriorityLow, PriorityMedium, PriorityHigh, PriorityUrgent}
+
+// 空字符串视为 medium；同时接受常见的中文写法
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "medium", "normal", "中", "普通", "一般":
+		return PriorityMedium, nil
+	case "low", "低":
+		return PriorityLow, nil
+	case "high", "高", "重要":
+		return PriorityHigh, nil
+	case "urgent", "紧急":
+		return PriorityUrgent, nil
+	}
+	return "", fmt.Errorf("未知的优先级 %q，可选 low / medium / high / urgent", s)
+}
+
+func (p Priority) rank() int {
+	return slices.Index(priorities, p)
+}
+
+type Status string
+
+const (
+	StatusTodo Status = "todo"
+	StatusDone Status = "done"
+)
+
+type Task struct {
+	ID          int        `json:"id"`
+	Title       string     `json:"title"`
+	Due         *time.Time `json:"due,omitempty"`
+	Priority    Priority   `json:"priority"`
+	Status      Status     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// 列表筛选条件，零值表示不限
+type Filter struct {
+	Status   Status
+	Priority Priority
+	// 只返回截止时间早于该时间的任务
+	DueBefore *time.Time
+}
+
+func (f Filter) match(t *Task) bool {
+	if f.Status != "" && t.Status != f.Status {
+		return false
+	}
+	if f.Priority != "" && t.Priority != f.Priority {
+		return false
+	}
+	if f.DueBefore != nil && (t.Due == nil || !t.Due.Before(*f.DueBefore)) {
+		return false
+	}
+	return true
+}
+
+// Store 把任务保存在本地 JSON 文件里，每次修改后整体写回（先写临时文件再改名，避免写一半）
+type Store struct {
+	mu     sync.Mutex
+	path   string
+	nextID int
+	tasks  []*Task
+}
+
+type storeFile struct {
+	NextID int     `json:"next_id"`
+	Tasks  []*Task `json:"tasks"`
+}
+
+// path 为空时使用用户配置目录下的 agent-demo/tasks.json；文件不存在时从空列表开始
+func Open(path string) (*Store, error) {
+	if path == "" {
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("定位配置目录失败: %w", err)
+		}
+		path = filepath.Join(base, "agent-demo", "tasks.json")
+	}
+	s := &Store{path: path, nextID: 1}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f storeFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("解析任务文件 %s 失败: %w", path, err)
+	}
+	s.tasks = f.Tasks
+	s.nextID = max(f.NextID, 1)
+	for _, t := range s.tasks {
+		s.nextID = max(s.nextID, t.ID+1)
+	}
+	return s, nil
+}
+
+func (s *Store) Path() string {
+	return s.path
+}
+
+func (s *Store) Create(title string, due *time.Time, priority Priority) (Task, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return Task{}, errors.New("任务标题不能为空")
+	}
+	if priority == "" {
+		priority = PriorityMedium
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	t := &Task{
+		ID:        s.nextID,
+		Title:     title,
+		Due:       due,
+		Priority:  priority,
+		Status:    StatusTodo,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.tasks = append(s.tasks, t)
+	s.nextID++
+	if err := s.save(); err != nil {
+		s.tasks = s.tasks[:len(s.tasks)-1]
+		s.nextID--
+		return Task{}, err
+	}
+	return *t, nil
+}
+
+func (s *Store) Get(id int) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.find(id)
+	if t == nil {
+		return Task{}, fmt.Errorf("%w：#%d", ErrNotFound, id)
+	}
+	return *t, nil
+}
+
+// 未完成的在前，其次按截止时间（没有截止时间的排最后）、优先级从高到低
+func (s *Store) List(f Filter) []Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Task
+	for _, t := range s.tasks {
+		if f.match(t) {
+			out = append(out, *t)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Task) int {
+		if a.Status != b.Status {
+			if a.Status == StatusTodo {
+				return -1
+			}
+			return 1
+		}
+		switch {
+		case a.Due != nil && b.Due != nil && !a.Due.Equal(*b.Due):
+			return a.Due.Compare(*b.Due)
+		case a.Due != nil && b.Due == nil:
+			return -1
+		case a.Due == nil && b.Due != nil:
+			return 1
+		}
+		return b.Priority.rank() - a.Priority.rank()
+	})
+	return out
+}
+
+// 在锁内修改任务并保存；fn 返回错误或保存失败时不做任何改动
+func (s *Store) Update(id int, fn func(t *Task) error) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t := s.find(id)
+	if t == nil {
+		return Task{}, fmt.Errorf("%w：#%d", ErrNotFound, id)
+	}
+	old := *t
+	if err := fn(t); err != nil {
+		*t = old
+		return Task{}, err
+	}
+	t.ID = old.ID
+	t.UpdatedAt = time.Now()
+	if err := s.save(); err != nil {
+		*t = old
+		return Task{}, err
+	}
+	return *t, nil
+}
+
+// 已完成的任务再次完成时保持原来的完成时间
+func (s *Store) Complete(id int) (Task, error) {
+	return s.Update(id, func(t *Task) error {
+		if t.Status != StatusDone {
+			now := time.Now()
+			t.Status = StatusDone
+			t.CompletedAt = &now
+		}
+		return nil
+	})
+}
+
+func (s *Store) Delete(id int) (Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.tasks, func(t *Task) bool { return t.ID == id })
+	if i < 0 {
+		return Task{}, fmt.Errorf("%w：#%d", ErrNotFound, id)
+	}
+	removed := s.tasks[i]
+	tasks := slices.Delete(slices.Clone(s.tasks), i, i+1)
+	old := s.tasks
+	s.tasks = tasks
+	if err := s.save(); err != nil {
+		s.tasks = old
+		return Task{}, err
+	}
+	return *removed, nil
+}
+
+func (s *Store) find(id int) *Task {
+	for _, t := range s.tasks {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// 调用方持有 mu
+func (s *Store) save() error {
+	data, err := json.MarshalIndent(storeFile{NextID: s.nextID, Tasks: s.tasks}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return fmt.Errorf("创建任务目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tasks-*.json")
+	if err != nil {
+		return fmt.Errorf("写入任务文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入任务文件失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入任务文件失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("写入任务文件失败: %w", err)
+	}
+	return nil
+}
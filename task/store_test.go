@@ -0,0 +1,132 @@
+package task
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 打开一个已有一个任务的 Store，然后让任务文件的位置被一个非空目录占住，之后的保存都会失败
+func newBrokenStore(t *testing.T) (*Store, Task) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2025, 3, 6, 18, 0, 0, 0, time.UTC)
+	created, err := s.Create("写周报", &due, PriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(path, "occupied"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return s, created
+}
+
+func assertUnchanged(t *testing.T, s *Store, want Task, nextID int) {
+	t.Helper()
+	tasks := s.List(Filter{})
+	if len(tasks) != 1 || !reflect.DeepEqual(tasks[0], want) {
+		t.Errorf("保存失败后任务列表 = %+v，期望只有 %+v", tasks, want)
+	}
+	if s.nextID != nextID {
+		t.Errorf("保存失败后 nextID = %d，期望 %d", s.nextID, nextID)
+	}
+}
+
+func TestCreateRollsBackOnSaveFailure(t *testing.T) {
+	s, created := newBrokenStore(t)
+	if _, err := s.Create("买菜", nil, PriorityLow); err == nil {
+		t.Fatal("保存失败时 Create 应返回错误")
+	}
+	assertUnchanged(t, s, created, 2)
+}
+
+func TestUpdateRollsBackOnSaveFailure(t *testing.T) {
+	s, created := newBrokenStore(t)
+	_, err := s.Update(created.ID, func(t *Task) error {
+		t.Title = "写月报"
+		t.Due = nil
+		t.Priority = PriorityLow
+		return nil
+	})
+	if err == nil {
+		t.Fatal("保存失败时 Update 应返回错误")
+	}
+	assertUnchanged(t, s, created, 2)
+
+	if _, err := s.Complete(created.ID); err == nil {
+		t.Fatal("保存失败时 Complete 应返回错误")
+	}
+	assertUnchanged(t, s, created, 2)
+}
+
+func TestUpdateRollsBackOnCallbackError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := s.Create("写周报", nil, PriorityMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errInvalid := errors.New("标题无效")
+	_, err = s.Update(created.ID, func(t *Task) error {
+		t.Title = ""
+		t.ID = 42
+		return errInvalid
+	})
+	if !errors.Is(err, errInvalid) {
+		t.Fatalf("应返回回调的错误，实际为 %v", err)
+	}
+	assertUnchanged(t, s, created, 2)
+}
+
+func TestDeleteRollsBackOnSaveFailure(t *testing.T) {
+	s, created := newBrokenStore(t)
+	if _, err := s.Delete(created.ID); err == nil {
+		t.Fatal("保存失败时 Delete 应返回错误")
+	}
+	assertUnchanged(t, s, created, 2)
+	if _, err := s.Delete(99); !errors.Is(err, ErrNotFound) {
+		t.Errorf("删除不存在的任务应返回 ErrNotFound，实际为 %v", err)
+	}
+}
+
+func TestStorePersistsAcrossOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := s.Create("一", nil, "")
+	second, _ := s.Create("二", nil, "")
+	if _, err := s.Delete(second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tasks := reopened.List(Filter{}); len(tasks) != 1 || tasks[0].ID != first.ID {
+		t.Errorf("重新打开后任务列表 = %+v", tasks)
+	}
+	// 删除的编号不会被复用
+	third, err := reopened.Create("三", nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.ID != 3 {
+		t.Errorf("新任务编号 = %d，期望 3", third.ID)
+	}
+}
@@ -0,0 +1,225 @@
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"agent-demo/agent"
+)
+
+var weekdayNames = [...]string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// 交给模型的任务视图：截止时间同时给出 RFC3339 和便于复述的中文写法
+type taskView struct {
+	ID       int      `json:"id"`
+	Title    string   `json:"title"`
+	Due      string   `json:"due,omitempty"`
+	DueText  string   `json:"due_text,omitempty"`
+	Overdue  bool     `json:"overdue,omitempty"`
+	Priority Priority `json:"priority"`
+	Status   Status   `json:"status"`
+}
+
+type createArgs struct {
+	Title    string `json:"title" desc:"任务标题，简洁描述要做的事" required:"true" min:"1" max:"100"`
+	Due      string `json:"due,omitempty" desc:"截止时间，直接使用用户的原话即可，如“下周三下午”“明天 15:00”“3天后”，也可以是 2025-03-05 18:00；不设截止时间时留空"`
+	Priority string `json:"priority,omitempty" desc:"优先级，默认 medium" enum:"low,medium,high,urgent"`
+}
+
+type listArgs struct {
+	Status    string `json:"status,omitempty" desc:"按状态筛选，默认 todo" enum:"todo,done,all"`
+	Priority  string `json:"priority,omitempty" desc:"按优先级筛选，不填表示全部" enum:"low,medium,high,urgent"`
+	DueBefore string `json:"due_before,omitempty" desc:"只列出在该时间之前到期的任务，写法同 create_task 的 due，如“本周五”"`
+}
+
+type updateArgs struct {
+	ID       int     `json:"id" desc:"任务编号" required:"true" min:"1"`
+	Title    *string `json:"title,omitempty" desc:"新标题，不修改时不要传" min:"1" max:"100"`
+	Due      *string `json:"due,omitempty" desc:"新的截止时间，写法同 create_task；传空字符串表示取消截止时间，不修改时不要传"`
+	Priority *string `json:"priority,omitempty" desc:"新的优先级，不修改时不要传" enum:"low,medium,high,urgent"`
+}
+
+type idArgs struct {
+	ID int `json:"id" desc:"任务编号" required:"true" min:"1"`
+}
+
+type listResult struct {
+	Count int        `json:"count"`
+	Now   string     `json:"now"`
+	Tasks []taskView `json:"tasks"`
+}
+
+type actionResult struct {
+	Action string   `json:"action"`
+	Task   taskView `json:"task"`
+}
+
+// toolset 把 Store 暴露为工具，截止时间按 loc 解析和展示
+type toolset struct {
+	store *Store
+	loc   *time.Location
+	now   func() time.Time
+}
+
+// RegisterTools 注册 create_task / list_tasks / update_task / complete_task / delete_task；loc 为 nil 时使用本地时区
+func RegisterTools(reg *agent.Registry, store *Store, loc *time.Location) error {
+	if loc == nil {
+		loc = time.Local
+	}
+	ts := &toolset{store: store, loc: loc, now: time.Now}
+	errs := []error{
+		agent.Register(reg, "create_task", "创建一个待办任务，可以带截止时间和优先级", ts.create),
+		agent.Register(reg, "list_tasks", "列出待办任务，按截止时间排序", ts.list),
+		agent.Register(reg, "update_task", "修改任务的标题、截止时间或优先级", ts.update),
+		agent.Register(reg, "complete_task", "把任务标记为已完成", ts.complete),
+		agent.Register(reg, "delete_task", "删除任务", ts.remove),
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ts *toolset) create(ctx context.Context, args createArgs) (actionResult, error) {
+	due, err := ts.parseDeadline(args.Due)
+	if err != nil {
+		return actionResult{}, err
+	}
+	priority, err := ParsePriority(args.Priority)
+	if err != nil {
+		return actionResult{}, err
+	}
+	t, err := ts.store.Create(args.Title, due, priority)
+	if err != nil {
+		return actionResult{}, err
+	}
+	return actionResult{Action: "created", Task: ts.view(t)}, nil
+}
+
+func (ts *toolset) list(ctx context.Context, args listArgs) (listResult, error) {
+	var f Filter
+	switch args.Status {
+	case "", string(StatusTodo):
+		f.Status = StatusTodo
+	case string(StatusDone):
+		f.Status = StatusDone
+	}
+	if args.Priority != "" {
+		p, err := ParsePriority(args.Priority)
+		if err != nil {
+			return listResult{}, err
+		}
+		f.Priority = p
+	}
+	before, err := ts.parseDue(args.DueBefore)
+	if err != nil {
+		return listResult{}, err
+	}
+	f.DueBefore = before
+
+	res := listResult{Now: ts.now().In(ts.loc).Format(time.RFC3339), Tasks: []taskView{}}
+	for _, t := range ts.store.List(f) {
+		res.Tasks = append(res.Tasks, ts.view(t))
+	}
+	res.Count = len(res.Tasks)
+	return res, nil
+}
+
+func (ts *toolset) update(ctx context.Context, args updateArgs) (actionResult, error) {
+	// 先在锁外解析，避免解析失败时修改了一半
+	var due *time.Time
+	if args.Due != nil {
+		var err error
+		if due, err = ts.parseDeadline(*args.Due); err != nil {
+			return actionResult{}, err
+		}
+	}
+	var priority Priority
+	if args.Priority != nil {
+		var err error
+		if priority, err = ParsePriority(*args.Priority); err != nil {
+			return actionResult{}, err
+		}
+	}
+	t, err := ts.store.Update(args.ID, func(t *Task) error {
+		if args.Title != nil {
+			if strings.TrimSpace(*args.Title) == "" {
+				return errors.New("任务标题不能为空")
+			}
+			t.Title = strings.TrimSpace(*args.Title)
+		}
+		if args.Due != nil {
+			t.Due = due
+		}
+		if args.Priority != nil {
+			t.Priority = priority
+		}
+		return nil
+	})
+	if err != nil {
+		return actionResult{}, err
+	}
+	return actionResult{Action: "updated", Task: ts.view(t)}, nil
+}
+
+func (ts *toolset) complete(ctx context.Context, args idArgs) (actionResult, error) {
+	t, err := ts.store.Complete(args.ID)
+	if err != nil {
+		return actionResult{}, err
+	}
+	return actionResult{Action: "completed", Task: ts.view(t)}, nil
+}
+
+func (ts *toolset) remove(ctx context.Context, args idArgs) (actionResult, error) {
+	t, err := ts.store.Delete(args.ID)
+	if err != nil {
+		return actionResult{}, err
+	}
+	return actionResult{Action: "deleted", Task: ts.view(t)}, nil
+}
+
+// 空字符串表示没有截止时间
+func (ts *toolset) parseDue(text string) (*time.Time, error) {
+	if text == "" {
+		return nil, nil
+	}
+	due, err := ParseDue(text, ts.now(), ts.loc)
+	if err != nil {
+		return nil, err
+	}
+	return &due, nil
+}
+
+// 新设的截止时间不能已经过去，把解析结果告诉模型，由它向用户确认（例如周日说的“本周一”）
+func (ts *toolset) parseDeadline(text string) (*time.Time, error) {
+	due, err := ts.parseDue(text)
+	if err != nil || due == nil {
+		return due, err
+	}
+	if now := ts.now(); due.Before(now) {
+		return nil, fmt.Errorf("截止时间 %q 解析为 %s，已经过去（现在是 %s），请向用户确认具体日期",
+			text, ts.formatTime(*due), ts.formatTime(now))
+	}
+	return due, nil
+}
+
+func (ts *toolset) formatTime(t time.Time) string {
+	t = t.In(ts.loc)
+	return fmt.Sprintf("%d年%d月%d日%s %s", t.Year(), t.Month(), t.Day(), weekdayNames[t.Weekday()], t.Format("15:04"))
+}
+
+func (ts *toolset) view(t Task) taskView {
+	v := taskView{ID: t.ID, Title: t.Title, Priority: t.Priority, Status: t.Status}
+	if t.Due != nil {
+		due := t.Due.In(ts.loc)
+		v.Due = due.Format(time.RFC3339)
+		v.DueText = fmt.Sprintf("%d月%d日%s %s", due.Month(), due.Day(), weekdayNames[due.Weekday()], due.Format("15:04"))
+		v.Overdue = t.Status == StatusTodo && due.Before(ts.now())
+	}
+	return v
+}
@@ -0,0 +1,55 @@
+package task
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestToolset(t *testing.T, now time.Time) *toolset {
+	t.Helper()
+	store, err := Open(filepath.Join(t.TempDir(), "tasks.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &toolset{store: store, loc: shanghai, now: func() time.Time { return now }}
+}
+
+func TestCreateRejectsPastDue(t *testing.T) {
+	// 周日
+	ts := newTestToolset(t, time.Date(2025, 3, 9, 10, 0, 0, 0, shanghai))
+	ctx := context.Background()
+
+	for _, due := range []string{"本周一", "2024-12-31", "今天早上8点"} {
+		_, err := ts.create(ctx, createArgs{Title: "写周报", Due: due})
+		if err == nil || !strings.Contains(err.Error(), "已经过去") {
+			t.Errorf("截止时间 %q 已经过去，应当报错，实际为 %v", due, err)
+		}
+	}
+	if n := len(ts.store.List(Filter{})); n != 0 {
+		t.Fatalf("被拒绝的任务不应保存，实际有 %d 个", n)
+	}
+
+	res, err := ts.create(ctx, createArgs{Title: "写周报", Due: "下周一上午10点"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Task.Due != "2025-03-10T10:00:00+08:00" {
+		t.Errorf("Due = %s", res.Task.Due)
+	}
+
+	due := "本周一"
+	if _, err := ts.update(ctx, updateArgs{ID: res.Task.ID, Due: &due}); err == nil {
+		t.Error("修改为已经过去的截止时间应当报错")
+	}
+	if got, _ := ts.store.Get(res.Task.ID); got.Due == nil || !got.Due.Equal(time.Date(2025, 3, 10, 10, 0, 0, 0, shanghai)) {
+		t.Errorf("修改失败后截止时间不应改变，实际为 %v", got.Due)
+	}
+
+	// 筛选条件可以是过去的时间
+	if _, err := ts.list(ctx, listArgs{DueBefore: "本周一"}); err != nil {
+		t.Errorf("due_before 不应限制为将来的时间: %v", err)
+	}
+}
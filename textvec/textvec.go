@@ -0,0 +1,113 @@
+// Package textvec 提供 rag 检索与语义路由共用的词袋向量：按标点和空白切词，
+// 词频归一化后用余弦相似度比较。语义路由的示例是没有空格的整句中文，另外切出汉字二元组
+package textvec
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+var punctuation = strings.NewReplacer(
+	"。", " ",
+	"，", " ",
+	"、", " ",
+	"；", " ",
+	"：", " ",
+	"！", " ",
+	"？", " ",
+	".", " ",
+	",", " ",
+	";", " ",
+	":", " ",
+	"!", " ",
+	"?", " ",
+)
+
+// FromText 把文本按 Tokenize 切词，转为归一化的词频向量
+func FromText(text string) map[string]float64 {
+	return fromTokens(Tokenize(text))
+}
+
+// FromTextWithBigrams 与 FromText 相同，但按 TokenizeWithBigrams 切词
+func FromTextWithBigrams(text string) map[string]float64 {
+	return fromTokens(TokenizeWithBigrams(text))
+}
+
+func fromTokens(tokens []string) map[string]float64 {
+	if len(tokens) == 0 {
+		return map[string]float64{}
+	}
+
+	vec := make(map[string]float64)
+	for _, token := range tokens {
+		vec[token]++
+	}
+
+	total := float64(len(tokens))
+	for key := range vec {
+		vec[key] /= total
+	}
+	return vec
+}
+
+// Tokenize 按标点和空白切词，统一转为小写
+func Tokenize(text string) []string {
+	return strings.Fields(punctuation.Replace(strings.ToLower(text)))
+}
+
+// TokenizeWithBigrams 在 Tokenize 的基础上额外切出汉字二元组：
+// 中文整句没有空格，只切词会得到一个 token，无法比较相似度
+func TokenizeWithBigrams(text string) []string {
+	fields := Tokenize(text)
+	result := make([]string, 0, len(fields))
+	for _, f := range fields {
+		result = append(result, f)
+		result = append(result, hanBigrams(f)...)
+	}
+	return result
+}
+
+func hanBigrams(s string) []string {
+	var grams []string
+	var prev rune
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			if prev != 0 {
+				grams = append(grams, string([]rune{prev, r}))
+			}
+			prev = r
+			continue
+		}
+		prev = 0
+	}
+	return grams
+}
+
+// Cosine 计算两个向量的余弦相似度，任一为空时返回 0
+func Cosine(a, b map[string]float64) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	var dot float64
+	var normA float64
+	var normB float64
+
+	for key, av := range a {
+		normA += av * av
+		if bv, ok := b[key]; ok {
+			dot += av * bv
+		}
+	}
+
+	for _, bv := range b {
+		normB += bv * bv
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,40 @@
+package textvec
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("Eino 的流式，怎么用？")
+	want := []string{"eino", "的流式", "怎么用"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Tokenize = %q，期望 %q", got, want)
+	}
+	got = TokenizeWithBigrams("Eino 的流式，怎么用？")
+	want = []string{"eino", "的流式", "的流", "流式", "怎么用", "怎么", "么用"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("TokenizeWithBigrams = %q，期望 %q", got, want)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	a := FromText("帮我写一个排序函数")
+	if got := Cosine(a, a); math.Abs(got-1) > 1e-9 {
+		t.Errorf("相同文本的相似度 = %v，期望 1", got)
+	}
+	if got := Cosine(a, FromText("今天天气怎么样")); got != 0 {
+		t.Errorf("无共同词的相似度 = %v，期望 0", got)
+	}
+	// 只按空白切词时整句中文是一个 token，汉字二元组让它们也能部分匹配
+	if got := Cosine(a, FromText("写一个快速排序")); got != 0 {
+		t.Errorf("FromText 下不同的整句中文相似度 = %v，期望 0", got)
+	}
+	if got := Cosine(FromTextWithBigrams("帮我写一个排序函数"), FromTextWithBigrams("写一个快速排序")); got <= 0 {
+		t.Errorf("部分相同的中文句子相似度 = %v，期望大于 0", got)
+	}
+	if got := Cosine(a, FromText("")); got != 0 {
+		t.Errorf("空文本的相似度 = %v，期望 0", got)
+	}
+}
@@ -0,0 +1,26 @@
+// Package tokens 提供路由能力检查与限流共用的 token 粗略估算，二者按同一口径计算才不会互相矛盾。
+package tokens
+
+import "unicode"
+
+const (
+	// 未指定 MaxTokens 时为输出预留的 token 数
+	DefaultOutputReserve = 1024
+	// 单张图片按固定 token 数估算，与常见视觉模型的中等分辨率开销相当
+	ImageEstimate = 1000
+	// 每条消息的角色、分隔符等格式开销
+	MessageOverhead = 4
+)
+
+// Estimate 粗略估算文本的 token 数：汉字等 CJK 字符各算 1 个 token，其余字符按 4 个字符 1 个 token
+func Estimate(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
@@ -2,20 +2,27 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"time"
+	_ "time/tzdata" // 没有系统时区库的环境（如精简容器）也能加载 -tz
 
-	"github.com/cloudwego/eino-ext/components/model/ark"
+	"agent-demo/agent"
+	"agent-demo/llm"
+	"agent-demo/router"
+	"agent-demo/task"
+
+	"github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 )
 
 // 定义一个简单工具：获取天气
 func getWeather(city string) string {
 	city = strings.TrimSpace(city)
-	// 模拟调用外部API
 	switch city {
 	case "北京":
 		return "北京今天多云，气温 18~25℃。"
@@ -26,85 +33,115 @@ func getWeather(city string) string {
 	}
 }
 
-func main() {
-	ctx := context.Background()
-	apiKey := os.Getenv("ARK_API_KEY")
-	baseURL := os.Getenv("ARK_BASE_URL")
-	modelID := os.Getenv("ARK_MODEL")
+// 设置 ARK_ROUTER_CONFIG 时用模型路由器替代单个模型，调用方无需任何改动
+func newChatModel(ctx context.Context) (model.ToolCallingChatModel, error) {
+	if routerConfig := os.Getenv("ARK_ROUTER_CONFIG"); routerConfig != "" {
+		return router.NewFromEnv(ctx, routerConfig)
+	}
 
-	if apiKey == "" || baseURL == "" || modelID == "" {
-		log.Fatal("请先设置 ARK_API_KEY / ARK_BASE_URL / ARK_MODEL 环境变量")
+	cfg, err := llm.ConfigFromEnv()
+	if err != nil {
+		return nil, err
 	}
+	if cfg.Provider == llm.ProviderMock && cfg.MockScript == "" {
+		cfg.MockScript = "tools/mock.yaml"
+	}
+	return llm.NewChatModel(ctx, cfg)
+}
+
+// getWeather 的参数，工具的 JSON Schema 由这些标签生成
+type weatherArgs struct {
+	City string `json:"city" desc:"城市名称" required:"true"`
+}
 
-	chat, err := ark.NewChatModel(ctx, &ark.ChatModelConfig{
-		BaseURL: baseURL,
-		APIKey:  apiKey,
-		Model:   modelID,
+// 注册本 Demo 提供的全部工具：天气查询 + 任务管理
+// latency 模拟外部天气接口的耗时，便于观察同一步内工具并行执行的效果
+func newRegistry(tasks *task.Store, loc *time.Location, latency time.Duration) (*agent.Registry, error) {
+	reg := agent.NewRegistry()
+	err := agent.Register(reg, "getWeather", "根据城市名称获取天气", func(ctx context.Context, args weatherArgs) (string, error) {
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+		return getWeather(args.City), nil
 	})
 	if err != nil {
-		log.Fatalf("初始化模型失败: %v", err)
+		return nil, err
 	}
-
-	// 定义工具描述，告诉模型能调用什么函数
-	toolDef := &schema.ToolInfo{
-		Name: "getWeather",
-		Desc: "根据城市名称获取天气",
-		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
-			"city": {
-				Type:     schema.String,
-				Desc:     "城市名称",
-				Required: true,
-			},
-		}),
+	if err := task.RegisterTools(reg, tasks, loc); err != nil {
+		return nil, err
 	}
+	return reg, nil
+}
+
+func main() {
+	query := flag.String("q", "北京和上海今天的天气怎么样？哪里更适合出门？", "提问内容")
+	maxSteps := flag.Int("max-steps", 5, "最多调用模型的次数，超过后停止并输出已有轨迹")
+	concurrency := flag.Int("concurrency", 4, "同一步内多个工具调用的最大并发数，1 表示逐个执行")
+	maxRetries := flag.Int("max-retries", 2, "工具参数校验失败时允许模型修正重试的次数")
+	tasksPath := flag.String("tasks", "", "任务文件路径，默认为用户配置目录下的 agent-demo/tasks.json")
+	tz := flag.String("tz", "Asia/Shanghai", "解析与展示任务截止时间使用的时区")
+	weatherLatency := flag.Duration("weather-latency", 0, "模拟天气接口的耗时，如 300ms，用于对比并行与逐个执行（-concurrency 1）的总耗时")
+	flag.Parse()
+
+	ctx := context.Background()
 
-	// 将工具绑定到模型实例
-	toolChat, err := chat.WithTools([]*schema.ToolInfo{toolDef})
+	chat, err := newChatModel(ctx)
 	if err != nil {
-		log.Fatalf("绑定工具失败: %v", err)
+		log.Fatalf("初始化模型失败: %v", err)
 	}
 
-	// 用户输入
-	user := schema.UserMessage("请告诉我今天北京的天气。")
+	loc, err := time.LoadLocation(*tz)
+	if err != nil {
+		log.Fatalf("加载时区失败: %v", err)
+	}
+	tasks, err := task.Open(*tasksPath)
+	if err != nil {
+		log.Fatalf("打开任务文件失败: %v", err)
+	}
+	reg, err := newRegistry(tasks, loc, *weatherLatency)
+	if err != nil {
+		log.Fatalf("注册工具失败: %v", err)
+	}
 
-	// 调用模型（非流式）
-	resp, err := toolChat.Generate(ctx, []*schema.Message{
-		schema.SystemMessage("你是一个智能助手，可以调用工具获取天气信息。"),
-		user,
+	// 绑定工具，每一步的轨迹实时打印出来
+	a, err := agent.New(ctx, agent.Config{
+		Model:       chat,
+		Tools:       reg.Tools(),
+		MaxSteps:    *maxSteps,
+		Concurrency: *concurrency,
+		MaxRetries:  *maxRetries,
+		Trace:       os.Stdout,
 	})
 	if err != nil {
-		log.Fatalf("调用模型失败: %v", err)
+		log.Fatalf("创建 Agent 失败: %v", err)
 	}
 
-	// 打印模型的原始响应
-	fmt.Println("模型原始输出：", resp.Content)
-
-	// 检查是否包含 Tool 调用
-	if len(resp.ToolCalls) > 0 {
-		for _, call := range resp.ToolCalls {
-			fmt.Printf("🧩 模型请求调用工具：%s，参数：%s\n", call.Function.Name, call.Function.Arguments)
-
-			if call.Function.Name == "getWeather" {
-				var args struct {
-					City string `json:"city"`
-				}
-				_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
-				weather := getWeather(args.City)
-				fmt.Println("🌤 工具返回结果：", weather)
-
-				// 把工具返回结果再喂回模型，让它生成最终自然语言
-				final, err := toolChat.Generate(ctx, []*schema.Message{
-					schema.SystemMessage("你是智能助手"),
-					user,
-					schema.ToolMessage(weather, call.ID, schema.WithToolName(call.Function.Name)),
-				})
-				if err != nil {
-					log.Fatalf("生成最终回答失败: %v", err)
-				}
-				fmt.Println("💬 最终回答：", final.Content)
-			}
-		}
-	} else {
-		fmt.Println("模型没有请求调用任何工具。")
+	fmt.Println("📋 任务文件：", tasks.Path())
+	fmt.Println("👤 用户：", *query)
+	now := time.Now().In(loc)
+	res, err := a.Run(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf("你是一个智能助手，可以调用工具查询天气、管理待办任务。需要多个结果时可以在一次回复里同时调用多次工具，信息齐全后再回答。"+
+			"创建任务时截止时间直接使用用户的原话，由工具负责解析。当前时间：%s 星期%c（%s）。",
+			now.Format("2006-01-02 15:04"), []rune("日一二三四五六")[now.Weekday()], loc)),
+		schema.UserMessage(*query),
+	})
+	if errors.Is(err, agent.ErrMaxSteps) {
+		fmt.Printf("⚠️ 已执行 %d 步，模型仍在请求工具，停止循环\n", len(res.Steps))
+		return
 	}
+	if errors.Is(err, agent.ErrTooManyRetries) {
+		fmt.Println("⚠️ 模型多次给出无效的工具参数，停止循环：", err)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Agent 执行失败: %v", err)
+	}
+
+	usage := res.Usage()
+	fmt.Printf("📊 共 %d 步，tokens %d（输入 %d / 输出 %d）\n", len(res.Steps), usage.TotalTokens, usage.PromptTokens, usage.CompletionTokens)
+	fmt.Println("💬 最终回答：", res.Answer.Content)
 }
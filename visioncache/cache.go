@@ -0,0 +1,293 @@
+package visioncache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 各 Demo 的 -cache-ttl 默认值
+const DefaultTTL = 7 * 24 * time.Hour
+
+// 视觉分析结果的磁盘缓存：键为 模型ID + 调用参数 + 提示词 + 图片内容 的 SHA-256，
+// 同一张截图、同一提示词、同样的参数重复分析时直接复用上次结果。
+type Cache struct {
+	dir      string
+	ttl      time.Duration
+	disabled bool
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// 缓存条目，除模型回复外记录一些便于排查的元数据
+type Entry struct {
+	Key         string          `json:"key"`
+	ModelID     string          `json:"model_id"`
+	ImageSHA256 []string        `json:"image_sha256"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LatencyMS   int64           `json:"latency_ms"`
+	Response    *schema.Message `json:"response"`
+}
+
+type Stats struct {
+	Hits   int64
+	Misses int64
+}
+
+func (s Stats) String() string {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return "命中 0 / 未命中 0"
+	}
+	return fmt.Sprintf("命中 %d / 未命中 %d（命中率 %.0f%%）", s.Hits, s.Misses, float64(s.Hits)*100/float64(total))
+}
+
+// dir 为空时使用用户缓存目录；ttl<=0 表示永不过期
+func New(dir string, ttl time.Duration) (*Cache, error) {
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(base, "agent-demo", "vision")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, ttl: ttl}, nil
+}
+
+// 关闭缓存后 Generate 总是直接调用模型（对应 --no-cache）
+func (c *Cache) Disable() {
+	c.disabled = true
+}
+
+func (c *Cache) Stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// 带缓存的生成：仅对包含图片的请求生效，其余请求原样透传
+func (c *Cache) Generate(ctx context.Context, chat model.BaseChatModel, modelID string, msgs []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	if c == nil || c.disabled || !hasImage(msgs) {
+		return chat.Generate(ctx, msgs, opts...)
+	}
+
+	key, imageHashes, err := Key(modelID, msgs, opts...)
+	if err != nil {
+		// 图片无法计算哈希（例如 data URL 不规范）时不走缓存，交给模型自行处理
+		return chat.Generate(ctx, msgs, opts...)
+	}
+	if entry, ok := c.Get(key); ok {
+		c.hits.Add(1)
+		return entry.Response, nil
+	}
+	c.misses.Add(1)
+
+	start := time.Now()
+	resp, err := chat.Generate(ctx, msgs, opts...)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{
+		Key:         key,
+		ModelID:     modelID,
+		ImageSHA256: imageHashes,
+		CreatedAt:   time.Now(),
+		LatencyMS:   time.Since(start).Milliseconds(),
+		Response:    resp,
+	}
+	// 写缓存失败不影响本次结果
+	_ = c.Put(entry)
+	return resp, nil
+}
+
+func (c *Cache) Get(key string) (*Entry, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Response == nil {
+		return nil, false
+	}
+	if c.ttl > 0 && time.Since(entry.CreatedAt) > c.ttl {
+		_ = os.Remove(c.path(key))
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (c *Cache) Put(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免并发读到半截内容
+	tmp, err := os.CreateTemp(c.dir, entry.Key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(entry.Key))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+type keyOptions struct {
+	salt string
+}
+
+// WithSalt 把调用方自定义的区分信息加入缓存键。通用参数（temperature、max_tokens 等）已经计入，
+// 厂商专有的选项（如 response_format）缓存无法识别，使用这类选项时应传入能区分它们的 salt。
+// 该选项会随其它选项传给模型，模型实现会忽略不认识的专有选项。
+func WithSalt(salt string) model.Option {
+	return model.WrapImplSpecificOptFn(func(o *keyOptions) {
+		o.salt = salt
+	})
+}
+
+// 计入缓存键的通用调用参数
+type keyParams struct {
+	Temperature *float32           `json:"temperature,omitempty"`
+	MaxTokens   *int               `json:"max_tokens,omitempty"`
+	Model       *string            `json:"model,omitempty"`
+	TopP        *float32           `json:"top_p,omitempty"`
+	Stop        []string           `json:"stop,omitempty"`
+	Tools       []keyTool          `json:"tools,omitempty"`
+	ToolChoice  *schema.ToolChoice `json:"tool_choice,omitempty"`
+	Salt        string             `json:"salt,omitempty"`
+}
+
+type keyTool struct {
+	Name   string `json:"name"`
+	Desc   string `json:"desc"`
+	Params any    `json:"params,omitempty"`
+}
+
+// 计算缓存键：模型ID、调用参数、每条消息的角色与文本、每张图片内容的哈希依次写入 SHA-256
+func Key(modelID string, msgs []*schema.Message, opts ...model.Option) (string, []string, error) {
+	h := sha256.New()
+	var imageHashes []string
+	writeField(h, "model", modelID)
+	params, err := callParams(opts)
+	if err != nil {
+		return "", nil, err
+	}
+	writeField(h, "params", params)
+	for _, msg := range msgs {
+		writeField(h, "role", string(msg.Role))
+		writeField(h, "content", msg.Content)
+		for _, part := range msg.MultiContent {
+			switch part.Type {
+			case schema.ChatMessagePartTypeText:
+				writeField(h, "text", part.Text)
+			case schema.ChatMessagePartTypeImageURL:
+				if part.ImageURL == nil {
+					continue
+				}
+				sum, err := imageSHA256(part.ImageURL.URL)
+				if err != nil {
+					return "", nil, err
+				}
+				imageHashes = append(imageHashes, sum)
+				writeField(h, "image", sum)
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), imageHashes, nil
+}
+
+func callParams(opts []model.Option) (string, error) {
+	common := model.GetCommonOptions(nil, opts...)
+	p := keyParams{
+		Temperature: common.Temperature,
+		MaxTokens:   common.MaxTokens,
+		Model:       common.Model,
+		TopP:        common.TopP,
+		Stop:        common.Stop,
+		ToolChoice:  common.ToolChoice,
+		Salt:        model.GetImplSpecificOptions(&keyOptions{}, opts...).salt,
+	}
+	for _, t := range common.Tools {
+		kt := keyTool{Name: t.Name, Desc: t.Desc}
+		if t.ParamsOneOf != nil {
+			s, err := t.ParamsOneOf.ToJSONSchema()
+			if err != nil {
+				return "", fmt.Errorf("工具 %s 的参数定义: %w", t.Name, err)
+			}
+			kt.Params = s
+		}
+		p.Tools = append(p.Tools, kt)
+	}
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func writeField(w io.Writer, name, value string) {
+	fmt.Fprintf(w, "%s:%d:%s\n", name, len(value), value)
+}
+
+// 对图片原始字节求哈希：data URL 解码后计算，file:// 读取文件，其它远程 URL 只能按地址计算
+func imageSHA256(url string) (string, error) {
+	var data []byte
+	switch {
+	case strings.HasPrefix(url, "data:"):
+		i := strings.Index(url, ";base64,")
+		if i < 0 {
+			return "", errors.New("仅支持 base64 编码的 data URL")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(url[i+len(";base64,"):])
+		if err != nil {
+			return "", fmt.Errorf("解码图片 data URL 失败: %w", err)
+		}
+		data = decoded
+	case strings.HasPrefix(url, "file://"):
+		b, err := os.ReadFile(strings.TrimPrefix(url, "file://"))
+		if err != nil {
+			return "", err
+		}
+		data = b
+	default:
+		data = []byte(url)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func hasImage(msgs []*schema.Message) bool {
+	for _, msg := range msgs {
+		for _, part := range msg.MultiContent {
+			if part.Type == schema.ChatMessagePartTypeImageURL {
+				return true
+			}
+		}
+	}
+	return false
+}
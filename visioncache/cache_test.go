@@ -0,0 +1,106 @@
+package visioncache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// 每次调用返回递增的回复，便于区分是否命中缓存
+type countingModel struct {
+	calls int
+}
+
+func (m *countingModel) Generate(ctx context.Context, msgs []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.calls++
+	return schema.AssistantMessage(time.Now().String(), nil), nil
+}
+
+func (m *countingModel) Stream(ctx context.Context, msgs []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, nil
+}
+
+func imageMessage(url string) []*schema.Message {
+	msg := schema.UserMessage("")
+	msg.MultiContent = []schema.ChatMessagePart{
+		{Type: schema.ChatMessagePartTypeText, Text: "描述这张图"},
+		{Type: schema.ChatMessagePartTypeImageURL, ImageURL: &schema.ChatMessageImageURL{URL: url}},
+	}
+	return []*schema.Message{msg}
+}
+
+func TestKeyIncludesCallOptions(t *testing.T) {
+	msgs := imageMessage("data:image/png;base64,aGVsbG8=")
+	key := func(opts ...model.Option) string {
+		t.Helper()
+		k, _, err := Key("m", msgs, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+
+	base := key()
+	if key() != base {
+		t.Fatal("相同输入的缓存键不一致")
+	}
+	cases := map[string][]model.Option{
+		"temperature": {model.WithTemperature(0.2)},
+		"max_tokens":  {model.WithMaxTokens(100)},
+		"salt":        {WithSalt("json")},
+	}
+	for name, opts := range cases {
+		if key(opts...) == base {
+			t.Errorf("%s 没有计入缓存键", name)
+		}
+	}
+	if key(model.WithTemperature(0.2)) == key(model.WithTemperature(0.8)) {
+		t.Error("不同 temperature 得到了相同的缓存键")
+	}
+}
+
+func TestGenerateBypassesCacheWhenImageCannotBeHashed(t *testing.T) {
+	c, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := &countingModel{}
+	msgs := imageMessage("data:image/png,not-base64")
+	for range 2 {
+		if _, err := c.Generate(context.Background(), chat, "m", msgs); err != nil {
+			t.Fatalf("无法计算哈希时应直接调用模型，实际报错: %v", err)
+		}
+	}
+	if chat.calls != 2 {
+		t.Fatalf("模型调用次数 = %d，期望 2", chat.calls)
+	}
+}
+
+func TestGenerateHitsCache(t *testing.T) {
+	c, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chat := &countingModel{}
+	msgs := imageMessage("data:image/png;base64,aGVsbG8=")
+	first, err := c.Generate(context.Background(), chat, "m", msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Generate(context.Background(), chat, "m", msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chat.calls != 1 || first.Content != second.Content {
+		t.Fatalf("第二次调用应命中缓存，模型调用次数 = %d", chat.calls)
+	}
+	if _, err := c.Generate(context.Background(), chat, "m", msgs, model.WithMaxTokens(10)); err != nil {
+		t.Fatal(err)
+	}
+	if chat.calls != 2 {
+		t.Fatal("调用参数不同时不应复用缓存")
+	}
+}